@@ -1,16 +1,32 @@
 package config
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"path"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
 	"gopkg.in/yaml.v2"
 )
 
@@ -45,9 +61,47 @@ func dataSourceFilterSchema() *schema.Schema {
 				},
 				"values": {
 					Type:     schema.TypeList,
-					Required: true,
+					Optional: true,
 					Elem:     &schema.Schema{Type: schema.TypeString},
 				},
+				"operator": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "in",
+				},
+			},
+		},
+	}
+}
+
+func dataSourceStyleFilterSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeSet,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"column": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"strikethrough": {
+					Type:     schema.TypeBool,
+					Optional: true,
+					Default:  false,
+				},
+				"fill_color": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"font_color": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"action": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "exclude",
+				},
 			},
 		},
 	}
@@ -67,6 +121,18 @@ func dataSourceLookupSchema() *schema.Schema {
 					Type:     schema.TypeString,
 					Optional: true,
 				},
+				"excel_base64": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"archive": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"member": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
 				"password": {
 					Type:     schema.TypeString,
 					Optional: true,
@@ -91,6 +157,11 @@ func dataSourceLookupSchema() *schema.Schema {
 					Type:     schema.TypeString,
 					Optional: true,
 				},
+				"value_format": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "formatted",
+				},
 				"key_column": {
 					Type:     schema.TypeString,
 					Required: true,
@@ -104,6 +175,41 @@ func dataSourceLookupSchema() *schema.Schema {
 	}
 }
 
+func dataSourceChildSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeSet,
+		Optional: true,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"attribute": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"worksheet": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"excel": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"csv": {
+					Type:     schema.TypeString,
+					Optional: true,
+				},
+				"parent_key": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"child_key": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+			},
+		},
+	}
+}
+
 func buildConfigDataSourceParams(set *schema.Set) []map[string]interface{} {
 	var params []map[string]interface{}
 	for _, v := range set.List() {
@@ -116,20 +222,118 @@ func buildConfigDataSourceParams(set *schema.Set) []map[string]interface{} {
 	return params
 }
 
-func buildConfigDataSourceFilters(set *schema.Set) []map[string]interface{} {
+// Operators of the filter blocks
+var filterOperators = []string{"equals", "not_equals", "in", "not_in", "regex", "prefix", "gt", "lt", "empty", "not_empty"}
+
+func buildConfigDataSourceFilters(set *schema.Set) ([]map[string]interface{}, error) {
 	var filters []map[string]interface{}
 	for _, v := range set.List() {
 		m := v.(map[string]interface{})
+		name := m["name"].(string)
 		var filterValues []string
 		for _, e := range m["values"].([]interface{}) {
 			filterValues = append(filterValues, fmt.Sprintf("%v", e))
 		}
+		operator := strings.ToLower(m["operator"].(string))
+		if !stringInList(operator, filterOperators) {
+			return nil, fmt.Errorf("invalid filter operator \"%s\". Valid values are %s", operator, strings.Join(filterOperators, ","))
+		}
 		mvalue := make(map[string]interface{})
-		mvalue["Name"] = m["name"].(string)
+		switch operator {
+		case "empty", "not_empty":
+			if len(filterValues) > 0 {
+				return nil, fmt.Errorf("filter \"%s\" with operator %s does not use values", name, operator)
+			}
+		case "equals", "not_equals", "gt", "lt":
+			if len(filterValues) != 1 {
+				return nil, fmt.Errorf("filter \"%s\" with operator %s requires 1 value", name, operator)
+			}
+			if operator == "gt" || operator == "lt" {
+				n, err := strconv.ParseFloat(strings.TrimSpace(filterValues[0]), 64)
+				if err != nil {
+					return nil, fmt.Errorf("filter \"%s\" with operator %s requires a number", name, operator)
+				}
+				mvalue["Number"] = n
+			}
+		default:
+			if len(filterValues) == 0 {
+				return nil, fmt.Errorf("filter \"%s\" with operator %s requires values", name, operator)
+			}
+			if operator == "regex" {
+				var patterns []*regexp.Regexp
+				for _, value := range filterValues {
+					re, err := regexp.Compile(value)
+					if err != nil {
+						return nil, fmt.Errorf("filter \"%s\" has an invalid regex: %v", name, err)
+					}
+					patterns = append(patterns, re)
+				}
+				mvalue["Patterns"] = patterns
+			}
+		}
+		mvalue["Name"] = name
 		mvalue["Values"] = filterValues
+		mvalue["Operator"] = operator
+		filters = append(filters, mvalue)
+	}
+	return filters, nil
+}
+
+func buildConfigDataSourceStyleFilters(set *schema.Set) ([]map[string]interface{}, error) {
+	var filters []map[string]interface{}
+	for _, v := range set.List() {
+		m := v.(map[string]interface{})
+		mvalue := make(map[string]interface{})
+		mvalue["Column"] = m["column"].(string)
+		mvalue["Strikethrough"] = m["strikethrough"].(bool)
+		mvalue["FillColor"] = normalizeColor(m["fill_color"].(string))
+		mvalue["FontColor"] = normalizeColor(m["font_color"].(string))
+		mvalue["Action"] = strings.ToLower(m["action"].(string))
+		if !stringInList(mvalue["Action"].(string), []string{"include", "exclude"}) {
+			return nil, fmt.Errorf("invalid style_filter action. Valid values are include,exclude")
+		}
+		if !mvalue["Strikethrough"].(bool) && mvalue["FillColor"] == "" && mvalue["FontColor"] == "" {
+			return nil, fmt.Errorf("style_filter on column \"%s\" requires strikethrough, fill_color or font_color", mvalue["Column"])
+		}
 		filters = append(filters, mvalue)
 	}
-	return filters
+	return filters, nil
+}
+
+// Normalize a RGB color (#FF0000, ff0000 or ARGB FFFF0000) to FF0000
+func normalizeColor(color string) string {
+	color = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(color), "#"))
+	if len(color) == 8 {
+		color = color[2:]
+	}
+	return color
+}
+
+func buildConfigDataSourceChildren(set *schema.Set) ([]map[string]interface{}, error) {
+	var children []map[string]interface{}
+	for _, v := range set.List() {
+		m := v.(map[string]interface{})
+		if (m["worksheet"].(string) == "") == (m["csv"].(string) == "") {
+			return nil, fmt.Errorf("child \"%s\" requires 1 source (worksheet/csv)", m["attribute"].(string))
+		}
+		if m["excel"].(string) != "" && m["worksheet"].(string) == "" {
+			return nil, fmt.Errorf("child \"%s\" excel requires worksheet", m["attribute"].(string))
+		}
+		for _, child := range children {
+			if child["Attribute"] == m["attribute"].(string) {
+				return nil, fmt.Errorf("child attribute \"%s\" is used more than once", m["attribute"].(string))
+			}
+		}
+		mvalue := make(map[string]interface{})
+		mvalue["Attribute"] = m["attribute"].(string)
+		mvalue["Worksheet"] = m["worksheet"].(string)
+		mvalue["Excel"] = m["excel"].(string)
+		mvalue["Csv"] = m["csv"].(string)
+		mvalue["ParentKey"] = m["parent_key"].(string)
+		mvalue["ChildKey"] = m["child_key"].(string)
+		children = append(children, mvalue)
+	}
+	return children, nil
 }
 
 func buildConfigDataSourceLookup(set *schema.Set) ([]map[string]interface{}, error) {
@@ -159,14 +363,58 @@ func buildConfigDataSourceLookup(set *schema.Set) ([]map[string]interface{}, err
 			return nil, fmt.Errorf("only 1 type of lookup source is required (worksheet/json/yaml)")
 		}
 
+		workbooks := 0
+		for _, k := range []string{"excel", "excel_base64", "archive"} {
+			if m[k].(string) != "" {
+				workbooks++
+			}
+		}
+		if workbooks > 1 {
+			return nil, fmt.Errorf("only 1 lookup workbook is required (excel/excel_base64/archive)")
+		}
+		if (m["archive"].(string) == "") != (m["member"].(string) == "") {
+			return nil, fmt.Errorf("lookup archive and member must be used together")
+		}
 		if m["worksheet"].(string) != "" {
 			if m["excel"].(string) != "" {
 				mvalue["Excel"] = m["excel"].(string)
 			}
+			if m["excel_base64"].(string) != "" {
+				data, err := base64.StdEncoding.DecodeString(m["excel_base64"].(string))
+				if err != nil {
+					return nil, fmt.Errorf("invalid lookup excel_base64: %v", err)
+				}
+				mvalue["ExcelData"] = data
+			}
+			if m["archive"].(string) != "" {
+				_, data, err := readArchiveMember(m["archive"].(string), m["member"].(string))
+				if err != nil {
+					return nil, err
+				}
+				if !isWorkbookData(data) {
+					return nil, fmt.Errorf("lookup member \"%s\" of archive \"%s\" is not an excel workbook", m["member"].(string), m["archive"].(string))
+				}
+				mvalue["ExcelData"] = data
+			}
+			// OpenDocument spreadsheets are converted once for all the looked up values
+			if excel_data, _ := mvalue["ExcelData"].([]byte); len(excel_data) > 0 || m["excel"].(string) != "" {
+				data, err := convertODS(m["excel"].(string), excel_data)
+				if err != nil {
+					return nil, err
+				}
+				if data != nil {
+					mvalue["ExcelData"] = data
+				}
+			}
 			if m["password"].(string) != "" {
 				mvalue["Password"] = m["password"].(string)
 			}
 			mvalue["Worksheet"] = m["worksheet"].(string)
+			value_format := strings.ToLower(m["value_format"].(string))
+			if !stringInList(value_format, []string{"formatted", "raw"}) {
+				return nil, fmt.Errorf("invalid lookup value_format. Valid values are formatted,raw")
+			}
+			mvalue["ValueFormat"] = value_format
 		} else {
 			mvalue["Excel"] = nil
 			mvalue["Worksheet"] = nil
@@ -209,7 +457,7 @@ func checkLookupValue(lookup []map[string]interface{}, key string) bool {
 	return false
 }
 
-func getLookupValue(lookup []map[string]interface{}, default_excel string, default_password string, default_worksheet string, key string, value string) (string, error) {
+func getLookupValue(lookup []map[string]interface{}, default_excel string, default_data []byte, default_password string, default_worksheet string, key string, value string) (string, error) {
 	var lookupValue = ""
 	for _, lv := range lookup {
 		if lv["Column"].(string) == key {
@@ -239,53 +487,71 @@ func getLookupValue(lookup []map[string]interface{}, default_excel string, defau
 				}
 			} else if lv["Worksheet"] != nil {
 				excel_file := default_excel
+				excel_data := default_data
 				excel_pass := default_password
 				// added external excel file as source lookup
 				if lv["Excel"] != nil {
 					excel_file = lv["Excel"].(string)
+					excel_data = nil
+				}
+				if lv["ExcelData"] != nil {
+					excel_file = ""
+					excel_data = lv["ExcelData"].([]byte)
 				}
 				// added property for password protected Excel worksheet
 				if lv["Password"] != nil {
 					excel_pass = lv["Password"].(string)
 				}
-				f, err := excelize.OpenFile(excel_file, excelize.Options{Password: excel_pass})
+				f, err := openWorkbook(excel_file, excel_data, excel_pass)
 				if err != nil {
 					return "", err
 				}
+				defer f.Close()
 				worksheet := ""
 				if lv["Worksheet"].(string) != "" {
 					worksheet = lv["Worksheet"].(string)
 				} else {
 					worksheet = default_worksheet
 				}
-				rows, err := f.GetRows(worksheet)
+				// stream the rows of the worksheet instead of loading all of them
+				rows, err := f.Rows(worksheet)
 				if err != nil {
-					return "", fmt.Errorf(fmt.Sprintf("%v", rows))
+					return "", err
 				}
+				defer rows.Close()
 
-				columns := len(rows[0])
-
-				// get column of key
-				header := rows[0]
+				lookupValue = ""
 				column_key := 0
 				column_value := -1
-				for i := 0; i < columns; i++ {
-					if header[i] == key {
-						column_key = i
+				for idx := 0; rows.Next(); idx++ {
+					row, err := rows.Columns(excelize.Options{RawCellValue: lv["ValueFormat"] == "raw"})
+					if err != nil {
+						return "", err
 					}
-					if header[i] == lv["Value"] {
-						column_value = i
+					// get column of key
+					if idx == 0 {
+						for i := range row {
+							if row[i] == key {
+								column_key = i
+							}
+							if row[i] == lv["Value"] {
+								column_value = i
+							}
+						}
+						if column_value < 0 {
+							break
+						}
 					}
-				}
-				// get row of key
-				if column_value >= 0 {
-					for _, row := range rows {
-						if row[column_key] == value {
+					// get row of key
+					if column_key < len(row) && row[column_key] == value {
+						lookupValue = ""
+						if column_value < len(row) {
 							lookupValue = row[column_value]
 						}
 					}
-				} else {
-					lookupValue = ""
+				}
+				if err := rows.Error(); err != nil {
+					return "", err
 				}
 			} else {
 				lookupValue = ""
@@ -295,15 +561,122 @@ func getLookupValue(lookup []map[string]interface{}, default_excel string, defau
 	return lookupValue, nil
 }
 
-func checkFiltersForItem(filters []map[string]interface{}, key string, value string) bool {
+// Check if a record is included by the filters. The value function returns
+// the typed value and the cell text of an attribute. With match "all" every
+// filter must match, with "any" at least one.
+func checkFilters(filters []map[string]interface{}, match string, value func(name string) (interface{}, string)) bool {
 	for _, fv := range filters {
-		if fv["Name"] == key {
-			for _, d := range fv["Values"].([]string) {
-				if d == value {
+		v, text := value(fv["Name"].(string))
+		matched := checkFilter(fv, v, text)
+		if match == "all" && !matched {
+			return false
+		}
+		if match != "all" && matched {
+			return true
+		}
+	}
+	return match == "all"
+}
+
+// Check if the value matches the filter. Equality also matches the cell text
+// so dates and formatted numbers can be filtered as displayed.
+func checkFilter(filter map[string]interface{}, value interface{}, text string) bool {
+	values := filter["Values"].([]string)
+	switch filter["Operator"].(string) {
+	case "empty":
+		return isEmptyFilterValue(value, text)
+	case "not_empty":
+		return !isEmptyFilterValue(value, text)
+	case "equals", "in":
+		return filterValueIn(value, text, values)
+	case "not_equals", "not_in":
+		return !filterValueIn(value, text, values)
+	case "regex":
+		for _, re := range filter["Patterns"].([]*regexp.Regexp) {
+			for _, s := range filterTexts(value, text) {
+				if re.MatchString(s) {
 					return true
 				}
 			}
 		}
+	case "prefix":
+		for _, prefix := range values {
+			for _, s := range filterTexts(value, text) {
+				if strings.HasPrefix(s, prefix) {
+					return true
+				}
+			}
+		}
+	case "gt", "lt":
+		n, ok := filterNumber(value)
+		if !ok {
+			return false
+		}
+		if filter["Operator"] == "gt" {
+			return n > filter["Number"].(float64)
+		}
+		return n < filter["Number"].(float64)
+	}
+	return false
+}
+
+// Check if the value equals one of the filter values. Numbers and booleans
+// are compared by value, each item of a list is compared.
+func filterValueIn(value interface{}, text string, values []string) bool {
+	for _, fv := range values {
+		switch v := value.(type) {
+		case float64:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(fv), 64); err == nil && n == v {
+				return true
+			}
+		case bool:
+			if b, err := strconv.ParseBool(strings.TrimSpace(fv)); err == nil && b == v {
+				return true
+			}
+		}
+		if stringInList(fv, filterTexts(value, text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the texts of the value compared by the filters
+func filterTexts(value interface{}, text string) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case nil:
+		return []string{text}
+	case string:
+		return []string{v, text}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64), text}
+	default:
+		return []string{fmt.Sprintf("%v", v), text}
+	}
+}
+
+func filterNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// A value is empty if the cell is empty or the attribute is not found
+func isEmptyFilterValue(value interface{}, text string) bool {
+	if value == nil || text == "" {
+		return true
+	}
+	if s, ok := value.(string); ok && s == "" {
+		return true
 	}
 	return false
 }
@@ -332,29 +705,316 @@ func stringToInterface(s string) (interface{}, error) {
 	return v, nil
 }
 
-func stringToMap(s string) ([]map[string]string, error) {
+// Create a csv reader using the csv dialect options
+func newCSVReader(s string, args *ConfigurationWorkbook) *csv.Reader {
+	if args.bom {
+		s = strings.TrimPrefix(s, "\uFEFF")
+	}
 	r := csv.NewReader(strings.NewReader(s))
+	if args.delimiter != "" {
+		r.Comma, _ = utf8.DecodeRuneInString(args.delimiter)
+	}
+	if args.comment != "" {
+		r.Comment, _ = utf8.DecodeRuneInString(args.comment)
+	}
+	r.LazyQuotes = args.lazy_quotes
+	r.TrimLeadingSpace = args.trim_leading_space
+	return r
+}
+
+func stringToMap(s string, args *ConfigurationWorkbook) ([]map[string]string, error) {
+	_, rows, _, err := readCSV(s, args)
+	return rows, err
+}
+
+// Read the header and the records of the csv. The line of each record is
+// returned (ex. line 3).
+func readCSV(s string, args *ConfigurationWorkbook) ([]string, []map[string]string, []string, error) {
+	r := newCSVReader(s, args)
+	r.FieldsPerRecord = -1
 	rows := []map[string]string{}
+	var lines []string
 	var header []string
+	var ragged []string
+	long := false
 	for {
 		record, err := r.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 		if header == nil {
 			header = record
-		} else {
-			dict := map[string]string{}
-			for i := range header {
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		if len(record) != len(header) {
+			ragged = append(ragged, fmt.Sprintf("line %d has %d fields", line, len(record)))
+			long = long || len(record) > len(header)
+			if args.ragged_rows == "skip" || args.ragged_rows == "error" || (args.ragged_rows == "pad" && len(record) > len(header)) {
+				continue
+			}
+		}
+		dict := map[string]string{}
+		for i := range header {
+			dict[header[i]] = ""
+			if i < len(record) {
 				dict[header[i]] = record[i]
 			}
-			rows = append(rows, dict)
 		}
+		rows = append(rows, dict)
+		lines = append(lines, fmt.Sprintf("line %d", line))
 	}
-	return rows, nil
+
+	// report the records having a different number of fields than the header
+	if len(ragged) > 0 {
+		detail := fmt.Sprintf("The header has %d fields: %s", len(header), strings.Join(ragged, ", "))
+		switch args.ragged_rows {
+		case "pad":
+			if long {
+				return nil, nil, nil, fmt.Errorf("csv has records with more fields than the header, set ragged_rows to truncate or skip. %s", detail)
+			}
+			addWarning(args, fmt.Sprintf("%d csv records were padded with empty fields", len(ragged)), detail)
+		case "truncate":
+			addWarning(args, fmt.Sprintf("%d csv records were padded or truncated to the header", len(ragged)), detail)
+		case "skip":
+			addWarning(args, fmt.Sprintf("%d csv records were skipped", len(ragged)), detail)
+		default:
+			return nil, nil, nil, fmt.Errorf("csv has %d records with a wrong number of fields, set ragged_rows to pad, truncate or skip. %s", len(ragged), detail)
+		}
+	}
+	return header, rows, lines, nil
+}
+
+// Limits of the downloaded files
+const (
+	maxDownloadSize = 256 << 20
+	downloadTimeout = 5 * time.Minute
+)
+
+// Download the content of the url using the http client of config_rest. The
+// content must match the sha256 checksum.
+func downloadFile(uri string, headers []map[string]interface{}, checksum string) ([]byte, error) {
+	reqparm := new(RequestParameters)
+	reqparm.uri = uri
+	reqparm.headers = headers
+	reqparm.method = "GET"
+	reqparm.timeout = downloadTimeout
+	reqparm.max_size = maxDownloadSize
+	data, status, err := sendRequest(reqparm)
+	if err != nil {
+		return nil, err
+	}
+	if status < 200 || status > 299 {
+		return nil, fmt.Errorf("unable to download %s: http status %d", uri, status)
+	}
+	sum := sha256.Sum256(data)
+	if actual := hex.EncodeToString(sum[:]); actual != checksum {
+		return nil, fmt.Errorf("sha256 of %s is %s, expected %s", uri, actual, checksum)
+	}
+	return data, nil
+}
+
+// Size limits of the files extracted from an archive
+const (
+	maxArchiveMemberSize = 256 << 20
+	maxArchiveTotalSize  = 1 << 30
+)
+
+// Read the member of a zip or tar.gz archive. The member is the path of the
+// file in the archive or a glob pattern matching exactly one file.
+func readArchiveMember(archive string, member string) (string, []byte, error) {
+	files, err := readArchive(archive, member)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(files) == 0 {
+		return "", nil, fmt.Errorf("no member of archive \"%s\" matches \"%s\"", archive, member)
+	}
+	if len(files) > 1 {
+		var names []string
+		for _, file := range files {
+			names = append(names, file.name)
+		}
+		return "", nil, fmt.Errorf("%d members of archive \"%s\" match \"%s\": %s", len(files), archive, member, strings.Join(names, ", "))
+	}
+	return files[0].name, files[0].data, nil
+}
+
+// A file extracted from an archive
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+// Extract the files of a zip or tar.gz archive matching the glob pattern. The
+// files are extracted in memory, the size of each file and the total size are
+// limited to guard against archive bombs.
+func readArchive(archive string, pattern string) ([]archiveFile, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid member \"%s\": %v", pattern, err)
+	}
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(file, magic); err != nil {
+		return nil, fmt.Errorf("archive \"%s\" is not a zip or tar.gz file", archive)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var files []archiveFile
+	total := int64(0)
+	extract := func(name string, size int64, r io.Reader) error {
+		if size > maxArchiveMemberSize {
+			return fmt.Errorf("member \"%s\" of archive \"%s\" is larger than %d bytes", name, archive, maxArchiveMemberSize)
+		}
+		data, err := io.ReadAll(io.LimitReader(r, maxArchiveMemberSize+1))
+		if err != nil {
+			return fmt.Errorf("unable to extract member \"%s\" of archive \"%s\": %v", name, archive, err)
+		}
+		if len(data) > maxArchiveMemberSize {
+			return fmt.Errorf("member \"%s\" of archive \"%s\" is larger than %d bytes", name, archive, maxArchiveMemberSize)
+		}
+		total += int64(len(data))
+		if total > maxArchiveTotalSize {
+			return fmt.Errorf("members of archive \"%s\" matching \"%s\" are larger than %d bytes", archive, pattern, maxArchiveTotalSize)
+		}
+		files = append(files, archiveFile{name: name, data: data})
+		return nil
+	}
+	match := func(name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+
+	if bytes.Equal(magic, []byte("PK\x03\x04")) {
+		info, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		z, err := zip.NewReader(file, info.Size())
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive \"%s\": %v", archive, err)
+		}
+		for _, zf := range z.File {
+			if zf.FileInfo().IsDir() || !match(zf.Name) {
+				continue
+			}
+			rc, err := zf.Open()
+			if err != nil {
+				return nil, fmt.Errorf("unable to extract member \"%s\" of archive \"%s\": %v", zf.Name, archive, err)
+			}
+			err = extract(zf.Name, int64(zf.UncompressedSize64), rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return files, nil
+	}
+
+	if magic[0] != 0x1f || magic[1] != 0x8b {
+		return nil, fmt.Errorf("archive \"%s\" is not a zip or tar.gz file", archive)
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read archive \"%s\": %v", archive, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive \"%s\": %v", archive, err)
+		}
+		name := strings.TrimPrefix(header.Name, "./")
+		if header.Typeflag != tar.TypeReg || !match(name) {
+			continue
+		}
+		if err := extract(name, header.Size, tr); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Check if the data is an excel workbook (zip package or encrypted workbook)
+func isWorkbookData(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04")) || bytes.HasPrefix(data, []byte{0xD0, 0xCF, 0x11, 0xE0})
+}
+
+// Check the csv dialect and encoding options
+func validateCSVOptions(args *ConfigurationWorkbook) error {
+	if utf8.RuneCountInString(args.delimiter) != 1 || strings.ContainsAny(args.delimiter, "\"\r\n") {
+		return fmt.Errorf("delimiter must be a single character other than a quote or a line break")
+	}
+	if args.comment != "" {
+		if utf8.RuneCountInString(args.comment) != 1 || strings.ContainsAny(args.comment, "\"\r\n") {
+			return fmt.Errorf("comment must be a single character other than a quote or a line break")
+		}
+		if args.comment == args.delimiter {
+			return fmt.Errorf("comment must be different from the delimiter")
+		}
+	}
+	encodings := map[string]string{
+		"utf8":         "utf-8",
+		"utf16":        "utf-16",
+		"cp1252":       "windows-1252",
+		"latin1":       "latin-1",
+		"iso-8859-1":   "latin-1",
+		"utf-8":        "utf-8",
+		"utf-16":       "utf-16",
+		"windows-1252": "windows-1252",
+		"latin-1":      "latin-1",
+	}
+	encoding, ok := encodings[args.encoding]
+	if !ok {
+		return fmt.Errorf("Invalid encoding. Valid values are utf-8,utf-16,windows-1252,latin-1")
+	}
+	args.encoding = encoding
+	return nil
+}
+
+// Convert the csv data to utf-8 text. UTF-16 without byte order mark is read
+// as little endian. The utf-8 byte order mark written by some tools in front
+// of windows-1252 and latin-1 data is removed if bom is set.
+func decodeCSV(data []byte, args *ConfigurationWorkbook) (string, error) {
+	if args.bom && args.encoding != "utf-16" {
+		data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	}
+	var decoder *encoding.Decoder
+	switch args.encoding {
+	case "utf-16":
+		bom := unicode.IgnoreBOM
+		if args.bom {
+			bom = unicode.UseBOM
+		}
+		decoder = unicode.UTF16(unicode.LittleEndian, bom).NewDecoder()
+	case "windows-1252":
+		decoder = charmap.Windows1252.NewDecoder()
+	case "latin-1":
+		decoder = charmap.ISO8859_1.NewDecoder()
+	default:
+		if !utf8.Valid(data) {
+			return "", fmt.Errorf("csv data is not valid utf-8, set the encoding of the data")
+		}
+		return string(data), nil
+	}
+	text, err := decoder.Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode csv data as %s: %v", args.encoding, err)
+	}
+	return string(text), nil
 }
 
 func iniParser(datastream interface{}) map[string]map[string]interface{} {
@@ -381,3 +1041,14 @@ func iniParser(datastream interface{}) map[string]map[string]interface{} {
 	}
 	return ini
 }
+
+func addWarning(args *ConfigurationWorkbook, summary string, detail string) {
+	if args.diagnostics == nil {
+		return
+	}
+	*args.diagnostics = append(*args.diagnostics, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  summary,
+		Detail:   detail,
+	})
+}
@@ -0,0 +1,279 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+func TestDownloadFile(t *testing.T) {
+	content := "configuration_item,name\nvm,web\n"
+	sum := sha256.Sum256([]byte(content))
+	checksum := hex.EncodeToString(sum[:])
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/servers.csv":
+			w.Write([]byte(content))
+		case "/private.csv":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(content))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	headers := []map[string]interface{}{{"Key": "Authorization", "Value": "Bearer token"}}
+	tests := []struct {
+		name     string
+		path     string
+		headers  []map[string]interface{}
+		checksum string
+		err      string
+	}{
+		{name: "matching checksum", path: "/servers.csv", checksum: checksum},
+		{name: "wrong checksum", path: "/servers.csv", checksum: strings.Repeat("0", 64), err: "sha256 of"},
+		{name: "not found", path: "/missing.csv", checksum: checksum, err: "http status 404"},
+		{name: "header sent", path: "/private.csv", headers: headers, checksum: checksum},
+		{name: "header missing", path: "/private.csv", checksum: checksum, err: "http status 401"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := downloadFile(server.URL+tt.path, tt.headers, tt.checksum)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != content {
+				t.Fatalf("expected %q, got %q", content, string(data))
+			}
+		})
+	}
+}
+
+func TestSendRequestMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	args := &RequestParameters{uri: server.URL, method: "GET", max_size: 99}
+	if _, _, err := sendRequest(args); err == nil || !strings.Contains(err.Error(), "larger than 99 bytes") {
+		t.Fatalf("expected size error, got %v", err)
+	}
+	args.max_size = 100
+	data, _, err := sendRequest(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 100 {
+		t.Fatalf("expected 100 bytes, got %d", len(data))
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	content := "name,cpu\nweb,2\ndb\napp,4,extra\n\"multi\nline\",8\n"
+	tests := []struct {
+		ragged_rows string
+		rows        []map[string]string
+		lines       []string
+		warning     string
+		err         string
+	}{
+		{ragged_rows: "error", err: "csv has 2 records with a wrong number of fields, set ragged_rows to pad, truncate or skip. The header has 2 fields: line 3 has 1 fields, line 4 has 3 fields"},
+		{ragged_rows: "pad", err: "csv has records with more fields than the header"},
+		{
+			ragged_rows: "truncate",
+			rows: []map[string]string{
+				{"name": "web", "cpu": "2"},
+				{"name": "db", "cpu": ""},
+				{"name": "app", "cpu": "4"},
+				{"name": "multi\nline", "cpu": "8"},
+			},
+			lines:   []string{"line 2", "line 3", "line 4", "line 5"},
+			warning: "2 csv records were padded or truncated to the header",
+		},
+		{
+			ragged_rows: "skip",
+			rows: []map[string]string{
+				{"name": "web", "cpu": "2"},
+				{"name": "multi\nline", "cpu": "8"},
+			},
+			lines:   []string{"line 2", "line 5"},
+			warning: "2 csv records were skipped",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ragged_rows, func(t *testing.T) {
+			var diags diag.Diagnostics
+			args := &ConfigurationWorkbook{ragged_rows: tt.ragged_rows, diagnostics: &diags}
+			header, rows, lines, err := readCSV(content, args)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(header, []string{"name", "cpu"}) {
+				t.Fatalf("unexpected header %v", header)
+			}
+			if !reflect.DeepEqual(rows, tt.rows) {
+				t.Fatalf("expected rows %v, got %v", tt.rows, rows)
+			}
+			if !reflect.DeepEqual(lines, tt.lines) {
+				t.Fatalf("expected lines %v, got %v", tt.lines, lines)
+			}
+			if len(diags) != 1 || diags[0].Summary != tt.warning {
+				t.Fatalf("expected warning %q, got %v", tt.warning, diags)
+			}
+		})
+	}
+}
+
+func TestReadCSVPad(t *testing.T) {
+	var diags diag.Diagnostics
+	args := &ConfigurationWorkbook{ragged_rows: "pad", diagnostics: &diags}
+	_, rows, lines, err := readCSV("name,cpu,env\nweb,2\ndb,4,prod\n", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]string{{"name": "web", "cpu": "2", "env": ""}, {"name": "db", "cpu": "4", "env": "prod"}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("expected rows %v, got %v", expected, rows)
+	}
+	if !reflect.DeepEqual(lines, []string{"line 2", "line 3"}) {
+		t.Fatalf("unexpected lines %v", lines)
+	}
+	if len(diags) != 1 || diags[0].Detail != "The header has 3 fields: line 2 has 2 fields" {
+		t.Fatalf("unexpected warnings %v", diags)
+	}
+}
+
+func TestCheckFilter(t *testing.T) {
+	resource := dataSourceFilterSchema().Elem.(*schema.Resource)
+	tests := []struct {
+		operator string
+		values   []interface{}
+		value    interface{}
+		text     string
+		match    bool
+	}{
+		{operator: "equals", values: []interface{}{"prod"}, value: "prod", text: "prod", match: true},
+		{operator: "equals", values: []interface{}{"2"}, value: 2.0, text: "2.00", match: true},
+		{operator: "equals", values: []interface{}{"2.0"}, value: 2.0, text: "2", match: true},
+		{operator: "equals", values: []interface{}{"TRUE"}, value: true, text: "TRUE", match: true},
+		{operator: "equals", values: []interface{}{"false"}, value: true, text: "TRUE", match: false},
+		{operator: "equals", values: []interface{}{"2024-01-31"}, value: "2024-01-31T00:00:00Z", text: "2024-01-31", match: true},
+		{operator: "not_equals", values: []interface{}{"prod"}, value: "dev", text: "dev", match: true},
+		{operator: "not_equals", values: []interface{}{"4"}, value: 4.0, text: "4", match: false},
+		{operator: "in", values: []interface{}{"dev", "qa"}, value: "qa", text: "qa", match: true},
+		{operator: "in", values: []interface{}{"web"}, value: []string{"db", "web"}, text: "", match: true},
+		{operator: "in", values: []interface{}{"dev", "qa"}, value: nil, text: "prod", match: false},
+		{operator: "not_in", values: []interface{}{"dev", "qa"}, value: "prod", text: "prod", match: true},
+		{operator: "not_in", values: []interface{}{"web"}, value: []string{"db", "web"}, text: "", match: false},
+		{operator: "regex", values: []interface{}{"^web[0-9]+$"}, value: "web12", text: "web12", match: true},
+		{operator: "regex", values: []interface{}{"^1[0-9]$"}, value: 16.0, text: "16", match: true},
+		{operator: "regex", values: []interface{}{"^db"}, value: "web1", text: "web1", match: false},
+		{operator: "prefix", values: []interface{}{"10.0.", "192.168."}, value: "192.168.1.1", text: "192.168.1.1", match: true},
+		{operator: "prefix", values: []interface{}{"10.0."}, value: []string{"172.16.0.1", "10.0.0.1"}, text: "", match: true},
+		{operator: "gt", values: []interface{}{"4"}, value: 8.0, text: "8", match: true},
+		{operator: "gt", values: []interface{}{"4"}, value: 4.0, text: "4", match: false},
+		{operator: "gt", values: []interface{}{"4"}, value: " 16 ", text: " 16 ", match: true},
+		{operator: "gt", values: []interface{}{"4"}, value: "many", text: "many", match: false},
+		{operator: "lt", values: []interface{}{"4.5"}, value: 4, text: "4", match: true},
+		{operator: "lt", values: []interface{}{"4"}, value: true, text: "TRUE", match: false},
+		{operator: "empty", value: nil, text: "", match: true},
+		{operator: "empty", value: "", text: "", match: true},
+		{operator: "empty", value: 0.0, text: "0", match: false},
+		{operator: "not_empty", value: false, text: "FALSE", match: true},
+		{operator: "not_empty", value: nil, text: "", match: false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %v %v", tt.operator, tt.values, tt.value), func(t *testing.T) {
+			set := schema.NewSet(schema.HashResource(resource), []interface{}{
+				map[string]interface{}{"name": "col", "values": tt.values, "operator": tt.operator},
+			})
+			filters, err := buildConfigDataSourceFilters(set)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if match := checkFilter(filters[0], tt.value, tt.text); match != tt.match {
+				t.Fatalf("expected %v, got %v", tt.match, match)
+			}
+		})
+	}
+}
+
+func TestCheckFilters(t *testing.T) {
+	filters := []map[string]interface{}{
+		{"Name": "env", "Values": []string{"prod"}, "Operator": "equals"},
+		{"Name": "cpu", "Values": []string{"4"}, "Operator": "gt", "Number": 4.0},
+	}
+	tests := []struct {
+		match  string
+		record map[string]interface{}
+		result bool
+	}{
+		{match: "any", record: map[string]interface{}{"env": "prod", "cpu": 2.0}, result: true},
+		{match: "any", record: map[string]interface{}{"env": "dev", "cpu": 2.0}, result: false},
+		{match: "all", record: map[string]interface{}{"env": "prod", "cpu": 2.0}, result: false},
+		{match: "all", record: map[string]interface{}{"env": "prod", "cpu": 8.0}, result: true},
+		{match: "all", record: map[string]interface{}{"cpu": 8.0}, result: false},
+	}
+	for _, tt := range tests {
+		value := func(name string) (interface{}, string) {
+			v := tt.record[name]
+			if v == nil {
+				return nil, ""
+			}
+			return v, fmt.Sprintf("%v", v)
+		}
+		if result := checkFilters(filters, tt.match, value); result != tt.result {
+			t.Fatalf("%s %v: expected %v, got %v", tt.match, tt.record, tt.result, result)
+		}
+	}
+}
+
+func TestBuildConfigDataSourceFilters(t *testing.T) {
+	resource := dataSourceFilterSchema().Elem.(*schema.Resource)
+	tests := []struct {
+		operator string
+		values   []interface{}
+		err      string
+	}{
+		{operator: "like", values: []interface{}{"a"}, err: "invalid filter operator \"like\""},
+		{operator: "empty", values: []interface{}{"a"}, err: "does not use values"},
+		{operator: "equals", values: []interface{}{"a", "b"}, err: "requires 1 value"},
+		{operator: "gt", values: []interface{}{"many"}, err: "requires a number"},
+		{operator: "in", err: "requires values"},
+		{operator: "regex", values: []interface{}{"("}, err: "invalid regex"},
+	}
+	for _, tt := range tests {
+		set := schema.NewSet(schema.HashResource(resource), []interface{}{
+			map[string]interface{}{"name": "col", "values": tt.values, "operator": tt.operator},
+		})
+		if _, err := buildConfigDataSourceFilters(set); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Fatalf("%s %v: expected error containing %q, got %v", tt.operator, tt.values, tt.err, err)
+		}
+	}
+}
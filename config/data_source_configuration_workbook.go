@@ -2,9 +2,15 @@ package config
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,22 +21,76 @@ import (
 	"github.com/xuri/excelize/v2"
 )
 
+// Number of columns available in a worksheet (A..XFD)
+const maxExcelColumns = 16384
+
 type ConfigurationWorkbook struct {
-	csv_string         string
-	config_schema      string
-	excel_file         string
-	excel_pass         string
-	sheet_name         string
-	sheet_headers      []interface{}
-	start_column       string
-	end_column         string
-	configuration_item string
-	col_config_item    string
-	orientation        string
-	filters            []map[string]interface{}
-	lookup             []map[string]interface{}
-	mapping            interface{}
-	csv                []map[string]string
+	csv_string             string
+	csv_base64             string
+	csv_url                string
+	csv_data               []byte
+	csv_files              []string
+	csv_glob               string
+	include_file_name      bool
+	archive                string
+	member                 string
+	excel_url              string
+	url_headers            []map[string]interface{}
+	checksum               string
+	delimiter              string
+	comment                string
+	lazy_quotes            bool
+	trim_leading_space     bool
+	encoding               string
+	bom                    bool
+	ragged_rows            string
+	config_schema          string
+	excel_file             string
+	excel_base64           string
+	excel_data             []byte
+	excel_pass             string
+	sheet_name             string
+	sheet_names            []string
+	include_sheet_name     bool
+	table                  string
+	defined_name           string
+	cell_range             string
+	header_row             int
+	first_data_row         int
+	last_data_row          int
+	detect_header          bool
+	formulas               string
+	value_format           string
+	merged_cells           string
+	include_hidden_rows    bool
+	include_hidden_columns bool
+	include_hidden_sheets  bool
+	sheet_headers          []interface{}
+	start_column           string
+	end_column             string
+	configuration_item     string
+	col_config_item        string
+	orientation            string
+	filters                []map[string]interface{}
+	filter_match           string
+	style_filters          []map[string]interface{}
+	lookup                 []map[string]interface{}
+	children               []map[string]interface{}
+	mapping                interface{}
+	csv                    []map[string]string
+	values                 []map[string]interface{}
+	sources                []string
+	native_types           bool
+	max_rows               int
+	nest_separator         string
+	order_by               []map[string]interface{}
+	distinct_on            []string
+	limit                  int
+	offset                 int
+	columns                []string
+	exclude_columns        []string
+	key_column             string
+	diagnostics            *diag.Diagnostics
 }
 
 func dataSourceConfigurationWorkbook() *schema.Resource {
@@ -46,6 +106,79 @@ func dataSourceConfigurationWorkbook() *schema.Resource {
 				Type:     schema.TypeString,
 				Optional: true,
 			},
+			"csv_base64": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"csv_url": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"csv_files": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"csv_glob": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"include_file_name": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"excel_url": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"url_header": dataSourceKeyValueSchema(),
+			"archive": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"member": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"sha256": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"delimiter": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  ",",
+			},
+			"comment": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"lazy_quotes": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"trim_leading_space": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"encoding": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "utf-8",
+			},
+			"bom": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+			"ragged_rows": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "error",
+			},
 			"schema": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -54,6 +187,10 @@ func dataSourceConfigurationWorkbook() *schema.Resource {
 				Type:     schema.TypeString,
 				Optional: true,
 			},
+			"excel_base64": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
 			"password": {
 				Type:     schema.TypeString,
 				Optional: true,
@@ -62,6 +199,128 @@ func dataSourceConfigurationWorkbook() *schema.Resource {
 				Type:     schema.TypeString,
 				Optional: true,
 			},
+			"worksheets": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"include_sheet_name": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"table": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"defined_name": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"range": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"header_row": {
+				Type:     schema.TypeInt,
+				Optional: true,
+			},
+			"first_data_row": {
+				Type:     schema.TypeInt,
+				Optional: true,
+			},
+			"last_data_row": {
+				Type:     schema.TypeInt,
+				Optional: true,
+			},
+			"detect_header": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"formulas": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "cached",
+			},
+			"value_format": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "formatted",
+			},
+			"merged_cells": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "first",
+			},
+			"include_hidden_rows": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"include_hidden_columns": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"include_hidden_sheets": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"native_types": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+			"max_rows": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Default:  0,
+			},
+			"nest_separator": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"order_by": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"distinct_on": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"limit": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Default:  0,
+			},
+			"offset": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Default:  0,
+			},
+			"columns": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"exclude_columns": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"key_column": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"keys": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 			"headers": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -88,8 +347,15 @@ func dataSourceConfigurationWorkbook() *schema.Resource {
 				Type:     schema.TypeString,
 				Optional: true,
 			},
-			"filter": dataSourceFilterSchema(),
-			"lookup": dataSourceLookupSchema(),
+			"match": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "any",
+			},
+			"filter":       dataSourceFilterSchema(),
+			"style_filter": dataSourceStyleFilterSchema(),
+			"lookup":       dataSourceLookupSchema(),
+			"child":        dataSourceChildSchema(),
 		},
 	}
 }
@@ -98,13 +364,68 @@ func dataSourceConfigurationItemRead(ctx context.Context, d *schema.ResourceData
 	var diags diag.Diagnostics
 
 	params := new(ConfigurationWorkbook)
+	params.diagnostics = &diags
 	params.csv_string = d.Get("csv").(string)
+	params.csv_base64 = d.Get("csv_base64").(string)
+	params.csv_url = strings.TrimSpace(d.Get("csv_url").(string))
+	for _, v := range d.Get("csv_files").([]interface{}) {
+		params.csv_files = append(params.csv_files, fmt.Sprintf("%v", v))
+	}
+	params.csv_glob = d.Get("csv_glob").(string)
+	params.include_file_name = d.Get("include_file_name").(bool)
+	params.excel_url = strings.TrimSpace(d.Get("excel_url").(string))
+	params.checksum = strings.ToLower(strings.TrimSpace(d.Get("sha256").(string)))
+	params.archive = d.Get("archive").(string)
+	params.member = d.Get("member").(string)
+	if v, ok := d.GetOk("url_header"); ok {
+		params.url_headers = buildConfigDataSourceParams(v.(*schema.Set))
+	}
+	params.delimiter = d.Get("delimiter").(string)
+	params.comment = d.Get("comment").(string)
+	params.lazy_quotes = d.Get("lazy_quotes").(bool)
+	params.trim_leading_space = d.Get("trim_leading_space").(bool)
+	params.encoding = strings.ToLower(d.Get("encoding").(string))
+	params.bom = d.Get("bom").(bool)
+	params.ragged_rows = strings.ToLower(d.Get("ragged_rows").(string))
 	params.config_schema = d.Get("schema").(string)
 	params.configuration_item = d.Get("configuration_item").(string)
 	params.col_config_item = d.Get("col_config_item").(string)
 	params.excel_file = d.Get("excel").(string)
+	params.excel_base64 = d.Get("excel_base64").(string)
 	params.excel_pass = d.Get("password").(string)
 	params.sheet_name = d.Get("worksheet").(string)
+	for _, v := range d.Get("worksheets").([]interface{}) {
+		params.sheet_names = append(params.sheet_names, fmt.Sprintf("%v", v))
+	}
+	params.include_sheet_name = d.Get("include_sheet_name").(bool)
+	params.table = d.Get("table").(string)
+	params.defined_name = d.Get("defined_name").(string)
+	params.cell_range = d.Get("range").(string)
+	params.header_row = d.Get("header_row").(int)
+	params.first_data_row = d.Get("first_data_row").(int)
+	params.last_data_row = d.Get("last_data_row").(int)
+	params.detect_header = d.Get("detect_header").(bool)
+	params.formulas = strings.ToLower(d.Get("formulas").(string))
+	params.native_types = d.Get("native_types").(bool)
+	params.max_rows = d.Get("max_rows").(int)
+	params.value_format = strings.ToLower(d.Get("value_format").(string))
+	params.merged_cells = strings.ToLower(d.Get("merged_cells").(string))
+	params.include_hidden_rows = d.Get("include_hidden_rows").(bool)
+	params.include_hidden_columns = d.Get("include_hidden_columns").(bool)
+	params.include_hidden_sheets = d.Get("include_hidden_sheets").(bool)
+	params.nest_separator = d.Get("nest_separator").(string)
+	for _, v := range d.Get("distinct_on").([]interface{}) {
+		params.distinct_on = append(params.distinct_on, fmt.Sprintf("%v", v))
+	}
+	params.limit = d.Get("limit").(int)
+	params.offset = d.Get("offset").(int)
+	for _, v := range d.Get("columns").([]interface{}) {
+		params.columns = append(params.columns, fmt.Sprintf("%v", v))
+	}
+	for _, v := range d.Get("exclude_columns").([]interface{}) {
+		params.exclude_columns = append(params.exclude_columns, fmt.Sprintf("%v", v))
+	}
+	params.key_column = d.Get("key_column").(string)
 	params.sheet_headers = d.Get("headers").([]interface{})
 	params.orientation = d.Get("orientation").(string)
 	params.start_column = d.Get("col_start").(string)
@@ -112,7 +433,33 @@ func dataSourceConfigurationItemRead(ctx context.Context, d *schema.ResourceData
 
 	// gather all filters
 	if v, ok := d.GetOk("filter"); ok {
-		params.filters = buildConfigDataSourceFilters(v.(*schema.Set))
+		var err error
+		params.filters, err = buildConfigDataSourceFilters(v.(*schema.Set))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	params.filter_match = strings.ToLower(d.Get("match").(string))
+
+	// gather the sort keys
+	for _, v := range d.Get("order_by").([]interface{}) {
+		fields := strings.Fields(fmt.Sprintf("%v", v))
+		if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && !stringInList(strings.ToLower(fields[1]), []string{"asc", "desc"})) {
+			return diag.FromErr(fmt.Errorf("invalid order_by \"%v\". Valid values are \"<column>\", \"<column> asc\" or \"<column> desc\"", v))
+		}
+		params.order_by = append(params.order_by, map[string]interface{}{
+			"Name":       fields[0],
+			"Descending": len(fields) == 2 && strings.ToLower(fields[1]) == "desc",
+		})
+	}
+
+	// gather all style filters
+	if v, ok := d.GetOk("style_filter"); ok {
+		var err error
+		params.style_filters, err = buildConfigDataSourceStyleFilters(v.(*schema.Set))
+		if err != nil {
+			return diag.FromErr(err)
+		}
 	}
 
 	// gather all lookups
@@ -124,28 +471,241 @@ func dataSourceConfigurationItemRead(ctx context.Context, d *schema.ResourceData
 		}
 	}
 
+	// gather all children
+	if v, ok := d.GetOk("child"); ok {
+		var err error
+		params.children, err = buildConfigDataSourceChildren(v.(*schema.Set))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+
 	// set the default configuration item column name
 	if params.col_config_item == "" {
 		params.col_config_item = "configuration_item"
 	}
 
 	// set default value for configuration_item
-	if params.configuration_item == "" && params.sheet_name != "" {
-		params.configuration_item = params.sheet_name
+	if params.configuration_item == "" {
+		if params.table != "" {
+			params.configuration_item = params.table
+		} else if params.defined_name != "" {
+			params.configuration_item = params.defined_name
+		} else if params.sheet_name != "" {
+			params.configuration_item = params.sheet_name
+		}
 	}
 
 	// ###### Start Validations ######
 
+	// remote sources are pinned with their sha256 checksum
+	if params.csv_url != "" || params.excel_url != "" {
+		if params.csv_url != "" && params.excel_url != "" {
+			return diag.FromErr(fmt.Errorf("Cannot use csv_url and excel_url on the same resource"))
+		}
+		if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(params.checksum) {
+			return diag.FromErr(fmt.Errorf("sha256 is required with csv_url and excel_url and must be a hex encoded sha256 checksum"))
+		}
+	} else if params.checksum != "" || len(params.url_headers) > 0 {
+		return diag.FromErr(fmt.Errorf("sha256 and url_header are only valid with csv_url or excel_url"))
+	}
+
+	// the workbook or csv is extracted from a zip or tar.gz archive
+	if params.archive != "" || params.member != "" {
+		if params.archive == "" || params.member == "" {
+			return diag.FromErr(fmt.Errorf("archive and member must be used together"))
+		}
+		for _, source := range []string{params.csv_string, params.csv_base64, params.csv_url, params.csv_glob, params.excel_file, params.excel_base64, params.excel_url} {
+			if source != "" || len(params.csv_files) > 0 {
+				return diag.FromErr(fmt.Errorf("Cannot use archive with csv or excel on the same resource"))
+			}
+		}
+		_, data, err := readArchiveMember(params.archive, params.member)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if isWorkbookData(data) {
+			params.excel_data = data
+		} else {
+			params.csv_data = data
+		}
+	}
+
+	// the workbook is read from the excel file, its base64 content or a url
+	excel_sources := 0
+	for _, source := range []string{params.excel_file, params.excel_base64, params.excel_url} {
+		if source != "" {
+			excel_sources++
+		}
+	}
+	if excel_sources > 1 {
+		return diag.FromErr(fmt.Errorf("Cannot use more than one of excel, excel_base64 and excel_url on the same resource"))
+	}
+	if params.excel_base64 != "" || params.excel_url != "" {
+		var data []byte
+		var err error
+		if params.excel_url != "" {
+			data, err = downloadFile(params.excel_url, params.url_headers, params.checksum)
+		} else if data, err = base64.StdEncoding.DecodeString(params.excel_base64); err != nil {
+			err = fmt.Errorf("invalid excel_base64: %v", err)
+		}
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if len(data) == 0 {
+			return diag.FromErr(fmt.Errorf("excel data is empty"))
+		}
+		params.excel_data = data
+	}
+	is_excel := params.excel_file != "" || len(params.excel_data) > 0
+
+	// csv options
+	csv_sources := 0
+	for _, source := range []string{params.csv_string, params.csv_base64, params.csv_url, params.csv_glob} {
+		if source != "" {
+			csv_sources++
+		}
+	}
+	if len(params.csv_files) > 0 {
+		csv_sources++
+	}
+	if csv_sources > 1 {
+		return diag.FromErr(fmt.Errorf("Cannot use more than one of csv, csv_base64, csv_url, csv_files and csv_glob on the same resource"))
+	}
+	if err := validateCSVOptions(params); err != nil {
+		return diag.FromErr(err)
+	}
+	csv_options := params.delimiter != "," || params.comment != "" || params.lazy_quotes || params.trim_leading_space || params.encoding != "utf-8"
+	if csv_options && is_excel {
+		return diag.FromErr(fmt.Errorf("delimiter, comment, lazy_quotes, trim_leading_space and encoding are only valid for csv"))
+	}
+	if !stringInList(params.ragged_rows, []string{"error", "pad", "truncate", "skip"}) {
+		return diag.FromErr(fmt.Errorf("Invalid ragged_rows. Valid values are error,pad,truncate,skip"))
+	}
+	if params.ragged_rows != "error" && is_excel {
+		return diag.FromErr(fmt.Errorf("ragged_rows is only valid for csv"))
+	}
+	if params.encoding != "utf-8" && params.csv_string != "" {
+		return diag.FromErr(fmt.Errorf("csv is utf-8 text, use csv_base64, csv_url, csv_files or csv_glob with encoding %s", params.encoding))
+	}
+	if params.csv_base64 != "" || params.csv_url != "" {
+		var err error
+		if params.csv_url != "" {
+			params.csv_data, err = downloadFile(params.csv_url, params.url_headers, params.checksum)
+		} else if params.csv_data, err = base64.StdEncoding.DecodeString(params.csv_base64); err != nil {
+			err = fmt.Errorf("invalid csv_base64: %v", err)
+		}
+		if err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if params.csv_data != nil {
+		var err error
+		params.csv_string, err = decodeCSV(params.csv_data, params)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if params.csv_string == "" {
+			return diag.FromErr(fmt.Errorf("csv data is empty"))
+		}
+	}
+
+	// csv_files and csv_glob read several csv files having the same columns
+	if params.csv_glob != "" {
+		files, err := filepath.Glob(params.csv_glob)
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("invalid csv_glob \"%s\": %v", params.csv_glob, err))
+		}
+		if len(files) == 0 {
+			return diag.FromErr(fmt.Errorf("csv_glob \"%s\" does not match any file", params.csv_glob))
+		}
+		sort.Strings(files)
+		params.csv_files = files
+	}
+	if params.include_file_name && len(params.csv_files) == 0 {
+		return diag.FromErr(fmt.Errorf("include_file_name requires csv_files or csv_glob"))
+	}
+	if len(params.csv_files) > 0 && is_excel {
+		return diag.FromErr(fmt.Errorf(fmt.Sprintf("%v", "Cannot use csv and excel on the same resource")))
+	}
+
 	// make sure csv or excel is used
-	if params.csv_string == "" && params.excel_file == "" {
+	if params.csv_string == "" && len(params.csv_files) == 0 && !is_excel {
 		return diag.FromErr(fmt.Errorf(fmt.Sprintf("%v", "Must use csv or excel on the resource")))
 	}
 
 	// make sure csv and excel is not on the same resource
-	if params.csv_string != "" && params.excel_file != "" {
+	if params.csv_string != "" && is_excel {
 		return diag.FromErr(fmt.Errorf(fmt.Sprintf("%v", "Cannot use csv and excel on the same resource")))
 	}
 
+	// worksheets reads every matching worksheet of the workbook
+	if len(params.sheet_names) > 0 && !is_excel {
+		return diag.FromErr(fmt.Errorf("worksheets is only valid for excel"))
+	}
+	if len(params.sheet_names) > 0 && (params.sheet_name != "" || params.table != "" || params.defined_name != "") {
+		return diag.FromErr(fmt.Errorf("worksheets cannot be used with worksheet, table or defined_name"))
+	}
+	if params.include_sheet_name && params.sheet_name == "" && len(params.sheet_names) == 0 {
+		return diag.FromErr(fmt.Errorf("include_sheet_name requires worksheet or worksheets"))
+	}
+
+	// table and defined_name are read from the excel workbook
+	if params.table != "" && params.defined_name != "" {
+		return diag.FromErr(fmt.Errorf("Cannot use table and defined_name on the same resource"))
+	}
+	if (params.table != "" || params.defined_name != "") && !is_excel {
+		return diag.FromErr(fmt.Errorf("table and defined_name are only valid for excel"))
+	}
+	if (params.table != "" || params.defined_name != "") && (params.start_column != "" || params.end_column != "") {
+		return diag.FromErr(fmt.Errorf("col_start and col_end cannot be used with table or defined_name"))
+	}
+
+	// range and the row window only apply to a worksheet
+	row_window := params.header_row != 0 || params.first_data_row != 0 || params.last_data_row != 0 || params.detect_header
+	if (params.cell_range != "" || row_window) && !is_excel {
+		return diag.FromErr(fmt.Errorf("range, header_row, first_data_row, last_data_row and detect_header are only valid for excel"))
+	}
+	if (params.cell_range != "" || row_window) && (params.table != "" || params.defined_name != "") {
+		return diag.FromErr(fmt.Errorf("range, header_row, first_data_row, last_data_row and detect_header cannot be used with table or defined_name"))
+	}
+	if params.cell_range != "" && (row_window || params.start_column != "" || params.end_column != "") {
+		return diag.FromErr(fmt.Errorf("range cannot be used with col_start, col_end, header_row, first_data_row, last_data_row or detect_header"))
+	}
+	if params.header_row < 0 || params.first_data_row < 0 || params.last_data_row < 0 {
+		return diag.FromErr(fmt.Errorf("header_row, first_data_row and last_data_row must be positive row numbers"))
+	}
+	if params.detect_header && params.header_row != 0 {
+		return diag.FromErr(fmt.Errorf("Cannot use header_row and detect_header on the same resource"))
+	}
+	if params.header_row != 0 && params.first_data_row != 0 && params.first_data_row <= params.header_row {
+		return diag.FromErr(fmt.Errorf("first_data_row must be after header_row"))
+	}
+	if params.first_data_row != 0 && params.last_data_row != 0 && params.last_data_row < params.first_data_row {
+		return diag.FromErr(fmt.Errorf("last_data_row must not be before first_data_row"))
+	}
+
+	if !stringInList(params.formulas, []string{"cached", "calculate", "raw"}) {
+		return diag.FromErr(fmt.Errorf("Invalid formulas. Valid values are cached,calculate,raw"))
+	}
+	if params.formulas != "cached" && !is_excel {
+		return diag.FromErr(fmt.Errorf("formulas is only valid for excel"))
+	}
+
+	if !stringInList(params.value_format, []string{"formatted", "raw"}) {
+		return diag.FromErr(fmt.Errorf("Invalid value_format. Valid values are formatted,raw"))
+	}
+	if params.value_format != "formatted" && !is_excel {
+		return diag.FromErr(fmt.Errorf("value_format is only valid for excel"))
+	}
+
+	if !stringInList(params.merged_cells, []string{"fill", "first", "error"}) {
+		return diag.FromErr(fmt.Errorf("Invalid merged_cells. Valid values are fill,first,error"))
+	}
+	if params.merged_cells != "first" && !is_excel {
+		return diag.FromErr(fmt.Errorf("merged_cells is only valid for excel"))
+	}
+
 	params.orientation = strings.ToLower(params.orientation)
 	valid_vertical_orientation := []string{"vertical", "vert", "v"}
 	valid_horizontal_orientation := []string{"horizontal", "horiz", "h"}
@@ -157,33 +717,91 @@ func dataSourceConfigurationItemRead(ctx context.Context, d *schema.ResourceData
 		return diag.FromErr(fmt.Errorf(fmt.Sprintf("%v", "Invalid type. Valid values are horizontal,vertical")))
 	}
 
-	if params.orientation == "vertical" && params.configuration_item == "" {
+	if params.orientation == "vertical" && params.configuration_item == "" && len(params.sheet_names) == 0 {
 		return diag.FromErr(fmt.Errorf(fmt.Sprintf("%v", "configuration_item is required if type is vertical")))
 	}
 
-	if params.orientation == "vertical" && params.csv_string != "" {
-		return diag.FromErr(fmt.Errorf(fmt.Sprintf("%v", "vertical orientation is only valid for excel")))
+	if params.max_rows < 0 {
+		return diag.FromErr(fmt.Errorf("max_rows must not be negative"))
+	}
+	if params.max_rows > 0 && !is_excel {
+		return diag.FromErr(fmt.Errorf("max_rows is only valid for excel"))
+	}
+
+	if len(params.style_filters) > 0 && !is_excel {
+		return diag.FromErr(fmt.Errorf("style_filter is only valid for excel"))
+	}
+
+	if !stringInList(params.filter_match, []string{"any", "all"}) {
+		return diag.FromErr(fmt.Errorf("Invalid match. Valid values are any,all"))
+	}
+
+	for _, child := range params.children {
+		if child["Worksheet"] != "" && child["Excel"] == "" && !is_excel {
+			return diag.FromErr(fmt.Errorf("child \"%s\" worksheet requires excel on the data source or on the child", child["Attribute"]))
+		}
+	}
+
+	if strings.ContainsAny(params.nest_separator, "[]") {
+		return diag.FromErr(fmt.Errorf("nest_separator cannot contain [ or ], these are used for list indexes"))
+	}
+
+	if params.limit < 0 || params.offset < 0 {
+		return diag.FromErr(fmt.Errorf("limit and offset must not be negative"))
+	}
+
+	if len(params.columns) > 0 && len(params.exclude_columns) > 0 {
+		return diag.FromErr(fmt.Errorf("Cannot use both columns and exclude_columns on the same resource"))
+	}
+
+	if params.orientation == "vertical" && row_window {
+		return diag.FromErr(fmt.Errorf("header_row, first_data_row, last_data_row and detect_header are only valid for horizontal orientation"))
 	}
 
 	// ###### End Validations ######
 
 	// check if excel is being used
-	if params.excel_file != "" {
-		csvstring, err := excelToCSV(params)
+	if is_excel {
+		csv, values, sources, err := excelToMap(params)
 		if err != nil {
 			return diag.FromErr(err)
 		}
-		params.csv_string = csvstring
-		// return diag.FromErr(fmt.Errorf(fmt.Sprintf("%v", csvstring)))
-	}
-
-	if params.csv_string != "" {
-		// convert the csv to map
-		csv, err := stringToMap(params.csv_string)
+		params.csv = csv
+		params.sources = sources
+		if params.native_types {
+			params.values = values
+		}
+	} else if len(params.csv_files) > 0 {
+		// union the records of all csv files
+		csv, sources, err := csvFilesToMap(params)
 		if err != nil {
 			return diag.FromErr(err)
 		}
 		params.csv = csv
+		params.sources = sources
+	} else if params.csv_string != "" {
+		if params.orientation == "vertical" {
+			// transpose the csv like a vertical worksheet
+			table, err := csvToTable(params.csv_string, params)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			if table != nil {
+				params.csv, _, params.sources = table.records()
+			}
+		} else {
+			// convert the csv to map
+			_, csv, sources, err := readCSV(params.csv_string, params)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			params.csv = csv
+			params.sources = sources
+		}
+	}
+
+	if len(params.csv) > 0 {
+		var err error
 
 		// get all unique configuration items
 		items := unique(getConfigurationItems(params.csv, params.col_config_item))
@@ -205,10 +823,37 @@ func dataSourceConfigurationItemRead(ctx context.Context, d *schema.ResourceData
 		params.mapping = mapping["config_schema"]
 
 		// remap all csv headers based on mapping configuration
-		records := reMapData(params)
+		records, err := reMapData(params)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		// embed the matching child rows in the records
+		if err := addChildren(records, params); err != nil {
+			return diag.FromErr(err)
+		}
+
+		// sort, dedupe and page the records
+		records, positions, err := selectRecords(records, params)
+		if err != nil {
+			return diag.FromErr(err)
+		}
 
 		// get the transformed data
-		data := getItemData(records, items, params.col_config_item)
+		var data string
+		var keys []string
+		if params.key_column != "" {
+			keys, err = getRecordKeys(records, positions, params)
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			data = getKeyedItemData(selectColumns(records, params), keys, items, params.col_config_item)
+		} else {
+			data = getItemData(selectColumns(records, params), items, params.col_config_item)
+		}
+		if err := d.Set("keys", keys); err != nil {
+			return diag.FromErr(err)
+		}
 
 		// set the data to the attribute json
 		if err := d.Set("json", data); err != nil {
@@ -219,7 +864,16 @@ func dataSourceConfigurationItemRead(ctx context.Context, d *schema.ResourceData
 		if params.configuration_item == "" {
 			params.configuration_item = params.sheet_name
 		}
-		if err := d.Set("json", "{\""+params.configuration_item+"\": []}"); err != nil {
+		data := "{}"
+		if params.configuration_item != "" && params.key_column != "" {
+			data = "{\"" + params.configuration_item + "\": {}}"
+		} else if params.configuration_item != "" {
+			data = "{\"" + params.configuration_item + "\": []}"
+		}
+		if err := d.Set("keys", []string{}); err != nil {
+			return diag.FromErr(err)
+		}
+		if err := d.Set("json", data); err != nil {
 			return diag.FromErr(err)
 		}
 	}
@@ -229,167 +883,577 @@ func dataSourceConfigurationItemRead(ctx context.Context, d *schema.ResourceData
 	return diags
 }
 
-func excelToCSV(args *ConfigurationWorkbook) (string, error) {
-	var row_arr = []string{
-		"A", "B", "C", "D", "E", "F", "G", "H", "I", "J", "K", "L", "M", "N", "O", "P", "Q", "R", "S", "T", "U", "V", "W", "X", "Y", "Z",
-		"AA", "AB", "AC", "AD", "AE", "AF", "AG", "AH", "AI", "AJ", "AK", "AL", "AM", "AN", "AO", "AP", "AQ", "AR", "AS", "AT", "AU", "AV", "AW", "AX", "AY", "AZ",
+// Read the worksheets of the workbook and merge all the rows. The native values
+// of the cells and the source of each row are returned.
+func excelToMap(args *ConfigurationWorkbook) ([]map[string]string, []map[string]interface{}, []string, error) {
+	// the converted ods workbook is also read by the worksheet scanner
+	data, err := convertODS(args.excel_file, args.excel_data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if data != nil {
+		args.excel_data = data
+	}
+
+	f, err := openWorkbook(args.excel_file, args.excel_data, args.excel_pass)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer f.Close()
+
+	if len(args.sheet_names) == 0 {
+		return sheetToMap(f, args)
+	}
+
+	sheets, err := matchWorksheets(f, args.sheet_names, args.include_hidden_sheets)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var rows []map[string]string
+	var values []map[string]interface{}
+	var sources []string
+	for _, sheet := range sheets {
+		sheet_args := *args
+		sheet_args.sheet_name = sheet
+		if sheet_args.configuration_item == "" {
+			sheet_args.configuration_item = sheet
+		}
+		sheet_rows, sheet_values, sheet_sources, err := sheetToMap(f, &sheet_args)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		rows = append(rows, sheet_rows...)
+		values = append(values, sheet_values...)
+		for _, source := range sheet_sources {
+			sources = append(sources, fmt.Sprintf("worksheet \"%s\" %s", sheet, source))
+		}
+	}
+	return rows, values, sources, nil
+}
+
+func sheetToMap(f *excelize.File, args *ConfigurationWorkbook) ([]map[string]string, []map[string]interface{}, []string, error) {
+	table, err := excelToTable(f, args)
+	if err != nil || table == nil {
+		return nil, nil, nil, err
+	}
+	if args.include_sheet_name {
+		table.addColumn("_sheet", args.sheet_name)
+	}
+	rows, values, sources := table.records()
+	return rows, values, sources, nil
+}
+
+// Table of the data read from the workbook. The first row of the worksheet is
+// the header, each row keeps the text and the native value of the cells and
+// where the row comes from (ex. row 5) for error messages.
+type dataTable struct {
+	header  []string
+	texts   [][]string
+	values  [][]interface{}
+	sources []string
+}
+
+// Add a row to the table, empty rows are ignored
+func (t *dataTable) addRow(texts []string, values []interface{}, source string) {
+	replacer := strings.NewReplacer(",", "", " ", "", "[]", "", "{}", "", "\"", "")
+	if replacer.Replace(strings.Join(texts, "")) == "" {
+		return
 	}
+	t.texts = append(t.texts, texts)
+	t.values = append(t.values, values)
+	t.sources = append(t.sources, source)
+}
+
+// Add a column having the same value on all rows
+func (t *dataTable) addColumn(name string, value string) {
+	t.header = append(t.header, name)
+	for i := range t.texts {
+		t.texts[i] = append(t.texts[i], value)
+		t.values[i] = append(t.values[i], nil)
+	}
+}
+
+// Convert the rows of the table to maps keyed by the header. The native values
+// and the source are returned for each row, cells without a native value are
+// left out. The rows of the table are released.
+func (t *dataTable) records() ([]map[string]string, []map[string]interface{}, []string) {
+	rows := make([]map[string]string, len(t.texts))
+	values := make([]map[string]interface{}, len(t.texts))
+	for r := range t.texts {
+		rows[r] = make(map[string]string)
+		values[r] = make(map[string]interface{})
+		for i, name := range t.header {
+			rows[r][name] = t.texts[r][i]
+			if v := t.values[r][i]; v != nil {
+				values[r][name] = v
+			}
+		}
+		// the converted row is released
+		t.texts[r], t.values[r] = nil, nil
+	}
+	return rows, values, t.sources
+}
+
+// Describe where a row of a table comes from. Horizontal rows are a worksheet
+// row or a csv line, vertical rows are a worksheet column or a csv field.
+func cellSource(cells []excelCell, vertical bool, is_csv bool) string {
+	for _, cell := range cells {
+		switch {
+		case vertical && cell.col > 0 && is_csv:
+			return fmt.Sprintf("field %d", cell.col)
+		case vertical && cell.col > 0:
+			name, _ := excelize.ColumnNumberToName(cell.col)
+			return "column " + name
+		case !vertical && cell.row > 0 && is_csv:
+			return fmt.Sprintf("line %d", cell.row)
+		case !vertical && cell.row > 0:
+			return fmt.Sprintf("row %d", cell.row)
+		}
+	}
+	return ""
+}
+
+// Convert the rows of the worksheet to a table. A nil table is returned when
+// the worksheet does not have data rows.
+func excelToTable(f *excelize.File, args *ConfigurationWorkbook) (*dataTable, error) {
 	min := 0
-	max := len(row_arr) - 1
+	max := maxExcelColumns - 1
 	if args.start_column != "" {
-		min = sliceIndex(row_arr, args.start_column)
-		if min == -1 {
-			min = 0
+		idx, err := columnNameToIndex(args.start_column)
+		if err != nil {
+			return nil, fmt.Errorf("invalid col_start: %v", err)
+		}
+		min = idx
+	}
+	if args.end_column != "" {
+		idx, err := columnNameToIndex(args.end_column)
+		if err != nil {
+			return nil, fmt.Errorf("invalid col_end: %v", err)
+		}
+		max = idx
+	}
+	if min > max {
+		return nil, fmt.Errorf("col_start \"%s\" must not be after col_end \"%s\"", args.start_column, args.end_column)
+	}
+	areas, err := getWorkbookAreas(f, args)
+	if err != nil {
+		return nil, err
+	}
+	// the rows are converted while reading unless the whole worksheet is needed
+	// for merged cells, formulas, vertical orientation or several areas
+	if args.orientation == "horizontal" && args.merged_cells == "first" && args.formulas != "calculate" && args.formulas != "raw" && len(areas) <= 1 {
+		return streamToTable(f, areas, min, max, args)
+	}
+
+	// Get all rows of the worksheet, table or defined name
+	rows, err := getWorkbookRows(f, areas, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) <= 0 {
+		return nil, fmt.Errorf("%s does not have data", describeWorkbookSource(args))
+	}
+
+	// delete empty rows or row containing non printable characters including white spaces
+	rows = delete_empty_row(rows)
+	if len(rows) <= 0 {
+		return nil, fmt.Errorf("%s does not have data", describeWorkbookSource(args))
+	}
+
+	// skip hidden rows and columns
+	rows = deleteHiddenRows(rows, args.orientation == "horizontal")
+	if len(rows) <= 0 {
+		return nil, fmt.Errorf("%s does not have visible data", describeWorkbookSource(args))
+	}
+	hidden_cols := getHiddenColumns(rows)
+
+	// filter the rows using the cell style
+	if len(args.style_filters) > 0 {
+		var excluded map[int]bool
+		rows, excluded, err = applyStyleFilters(f, rows, args)
+		if err != nil {
+			return nil, err
+		}
+		for i := range excluded {
+			hidden_cols[i] = true
+		}
+	}
+
+	if args.merged_cells == "error" {
+		row_len := len(rows[0])
+		for _, row := range rows {
+			for i, cell := range row {
+				if cell.merged != "" && (args.orientation == "vertical" || (i >= min && i <= max && i < row_len)) {
+					return nil, fmt.Errorf("%s has merged cells %s", describeWorkbookSource(args), cell.merged)
+				}
+			}
+		}
+	}
+
+	return rowsToTable(rows, hidden_cols, min, max, false, args), nil
+}
+
+// Convert the rows of the worksheet or of a single area to a table as they are
+// read, the rows of the worksheet are not kept. The header and data rows are
+// selected like applyRowWindow, empty, hidden and style filtered rows are
+// skipped like excelToTable.
+func streamToTable(f *excelize.File, areas []excelArea, min int, max int, args *ConfigurationWorkbook) (*dataTable, error) {
+	sheet := args.sheet_name
+	last_row := args.last_data_row
+	var area *excelArea
+	if len(areas) > 0 {
+		area = &areas[0]
+		sheet = area.sheet
+		last_row = area.end_row + 1
+	}
+	reader, err := openSheetReader(f, sheet, last_row, args)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.close()
+
+	var builder *tableBuilder
+	var filter *styleFilter
+	var errors []string
+	header, first := 0, args.first_data_row
+	if args.header_row > 0 && first == 0 {
+		first = args.header_row + 1
+	}
+	for {
+		ok, err := reader.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		errors = appendFormulaErrors(errors, reader.cells)
+		r, row := reader.row, reader.cells
+		if area != nil {
+			if r-1 < area.start_row {
+				continue
+			}
+			row = getAreaCells(row, r-1, *area)
+		}
+		printable := len(row) > 0 && is_printable(cellTexts(row))
+
+		if builder != nil {
+			if r >= first && printable && !reader.hidden && (filter == nil || filter.includeRow(row)) {
+				builder.addRow(row)
+			}
+			continue
+		}
+
+		// the header row is kept even if hidden
+		is_header := printable
+		if args.header_row > 0 {
+			// an empty header row is replaced by the first data row
+			is_header = printable && (r == args.header_row || (r > args.header_row && r >= first))
+		} else if args.detect_header {
+			is_header = false
+			for _, cell := range row {
+				if strings.TrimSpace(cell.text) == args.col_config_item {
+					is_header = true
+				}
+			}
+		}
+		if !is_header {
+			continue
+		}
+		header = r
+		if args.header_row == 0 && args.first_data_row > 0 && args.first_data_row <= r {
+			return nil, fmt.Errorf("first_data_row must be after the header row %d", r)
+		}
+		if first <= r {
+			first = r + 1
+		}
+
+		hidden_cols := make(map[int]bool)
+		if !args.include_hidden_columns {
+			for c := range reader.scanner.hidden_cols {
+				if area == nil {
+					hidden_cols[c] = true
+				} else if c >= area.start_col && c <= area.end_col {
+					hidden_cols[c-area.start_col] = true
+				}
+			}
+		}
+		builder = newTableBuilder(row, hidden_cols, min, max, false, args)
+		if len(args.style_filters) > 0 {
+			if filter, err = newStyleFilter(f, cellTexts(row), args); err != nil {
+				return nil, err
+			}
+		}
+	}
+	reportFormulaErrors(sheet, errors, args)
+
+	if header == 0 {
+		switch {
+		case args.header_row > reader.row:
+			return nil, fmt.Errorf("header row %d is beyond the last row of worksheet \"%s\"", args.header_row, args.sheet_name)
+		case args.detect_header:
+			return nil, fmt.Errorf("header row with column \"%s\" not found in worksheet \"%s\"", args.col_config_item, args.sheet_name)
+		case area == nil && args.header_row == 0 && (args.first_data_row > 0 || args.last_data_row > 0):
+			return nil, fmt.Errorf("header row %d is beyond the last row of worksheet \"%s\"", reader.row+1, args.sheet_name)
+		}
+		return nil, fmt.Errorf("%s does not have data", describeWorkbookSource(args))
+	}
+	return builder.result(), nil
+}
+
+// Builder of a horizontal table. The columns of the header between min and
+// max that are not hidden are used. A configuration_item column is added
+// before the first column when the header does not have one.
+type tableBuilder struct {
+	table             *dataTable
+	row_len           int
+	hidden_cols       map[int]bool
+	min               int
+	max               int
+	first             int
+	config_item_exist bool
+	is_csv            bool
+	args              *ConfigurationWorkbook
+}
+
+func newTableBuilder(header []excelCell, hidden_cols map[int]bool, min int, max int, is_csv bool, args *ConfigurationWorkbook) *tableBuilder {
+	b := &tableBuilder{table: &dataTable{}, row_len: len(header), hidden_cols: hidden_cols, min: min, max: max, first: -1, is_csv: is_csv, args: args}
+	// get the first visible column
+	for i := min; i <= max && i < b.row_len; i++ {
+		if !hidden_cols[i] {
+			b.first = i
+			break
+		}
+	}
+
+	// check if configuration item is in the column names
+	for i := 0; i < b.row_len; i++ {
+		if (i >= min) && (i <= max) && !hidden_cols[i] {
+			if (header[i].text == "configuration_item") || (header[i].text == args.col_config_item) {
+				b.config_item_exist = true
+			}
+		}
+	}
+	b.table.header, _ = b.cells(header, true)
+	return b
+}
+
+// Get the texts and values of the used columns of a row
+func (b *tableBuilder) cells(row []excelCell, is_header bool) ([]string, []interface{}) {
+	var texts []string
+	var values []interface{}
+	for i := 0; i < b.row_len; i++ {
+		if (i >= b.min) && (i <= b.max) && !b.hidden_cols[i] {
+			if !b.config_item_exist && i == b.first {
+				if is_header {
+					texts = append(texts, "configuration_item")
+				} else {
+					texts = append(texts, b.args.configuration_item)
+				}
+				values = append(values, nil)
+			}
+
+			if i >= len(row) {
+				texts = append(texts, "")
+				values = append(values, nil)
+			} else {
+				// replace with supplied header
+				if is_header && i > b.first && len(b.args.sheet_headers) > 0 && i <= len(b.args.sheet_headers) {
+					texts = append(texts, b.args.sheet_headers[i-1].(string))
+				} else {
+					texts = append(texts, row[i].text)
+				}
+				values = append(values, row[i].value)
+			}
+		}
+	}
+	return texts, values
+}
+
+// Add a data row to the table
+func (b *tableBuilder) addRow(row []excelCell) {
+	texts, values := b.cells(row, false)
+	b.table.addRow(texts, values, cellSource(row, false, b.is_csv))
+}
+
+// Get the table, nil when there are no data rows
+func (b *tableBuilder) result() *dataTable {
+	if len(b.table.texts) == 0 {
+		return nil
+	}
+	return b.table
+}
+
+// Convert the rows to a table. For horizontal orientation, the first row is
+// the header and the columns between min and max are used. For vertical
+// orientation, the first column is the header and each column is a row of the
+// table. A nil table is returned when there are no data rows.
+func rowsToTable(rows [][]excelCell, hidden_cols map[int]bool, min int, max int, is_csv bool, args *ConfigurationWorkbook) *dataTable {
+	if args.orientation == "horizontal" {
+		builder := newTableBuilder(rows[0], hidden_cols, min, max, is_csv, args)
+		for _, row := range rows[1:] {
+			builder.addRow(row)
 		}
+		return builder.result()
 	}
-	if args.end_column != "" {
-		max = sliceIndex(row_arr, args.end_column)
-		if max == -1 {
-			max = len(row_arr) - 1
+
+	table := &dataTable{}
+	// Get total number of columns
+	maxcol := 0
+	for _, row := range rows {
+		if len(row) > maxcol {
+			maxcol = len(row)
 		}
 	}
-	var csv = []string{}
 
-	f, err := excelize.OpenFile(args.excel_file, excelize.Options{Password: args.excel_pass})
-	if err != nil {
-		return "", err
+	// the first column is the header, rows without a name are ignored
+	var fields []int
+	table.header = []string{"configuration_item"}
+	for idx, row := range rows {
+		if strings.Trim(row[0].text, " ") != "" {
+			table.header = append(table.header, row[0].text)
+			fields = append(fields, idx)
+		}
 	}
-
-	// check if sheet is existing in the workbook
-	sheetId, err := f.GetSheetIndex(args.sheet_name)
-	if err != nil {
-		return "", err
+	for i := 1; i < maxcol; i++ {
+		if hidden_cols[i] {
+			continue
+		}
+		texts := []string{args.configuration_item}
+		values := []interface{}{nil}
+		var cells []excelCell
+		for _, idx := range fields {
+			if i > len(rows[idx])-1 {
+				texts = append(texts, "")
+				values = append(values, nil)
+			} else {
+				texts = append(texts, rows[idx][i].text)
+				values = append(values, rows[idx][i].value)
+				cells = append(cells, rows[idx][i])
+			}
+		}
+		table.addRow(texts, values, cellSource(cells, true, is_csv))
 	}
-	if sheetId < 0 {
-		return "", fmt.Errorf("worksheet \"%s\" not found", args.sheet_name)
+	if len(table.texts) == 0 {
+		return nil
 	}
+	return table
+}
 
-	// Get all rows
-	rows, err := f.GetRows(args.sheet_name)
-	if err != nil {
-		return "", err
-	}
-	if len(rows) <= 0 {
-		return "", fmt.Errorf("worksheet \"%s\" does not have data", args.sheet_name)
+// Convert vertical csv data to a table, the first field of each record is
+// the header
+func csvToTable(s string, args *ConfigurationWorkbook) (*dataTable, error) {
+	r := newCSVReader(s, args)
+	r.FieldsPerRecord = -1
+	var rows [][]excelCell
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		row := make([]excelCell, len(record))
+		for c, text := range record {
+			row[c] = excelCell{text: text, row: line, col: c + 1}
+		}
+		rows = append(rows, row)
 	}
-
-	// delete empty rows or row containing non printable characters including white spaces
 	rows = delete_empty_row(rows)
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	return rowsToTable(rows, map[int]bool{}, 0, maxExcelColumns-1, true, args), nil
+}
 
-	// get the number of columns
-	row_len := len(rows[0])
-
-	if args.orientation == "horizontal" {
-		// check if configuration item is in the column names
-		config_item_exist := false
-		for i := 0; i < row_len; i++ {
-			if (i >= min) && (i <= max) && (i < row_len) {
-				if (rows[0][i] == "configuration_item") || (rows[0][i] == args.col_config_item) {
-					config_item_exist = true
-				}
-			}
+// Read the csv files and union their records. All the files must have the same
+// columns as the first file, in any order.
+func csvFilesToMap(args *ConfigurationWorkbook) ([]map[string]string, []string, error) {
+	var rows []map[string]string
+	var sources []string
+	var columns []string
+	for i, file := range args.csv_files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		s, err := decodeCSV(data, args)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if s == "" {
+			return nil, nil, fmt.Errorf("csv file %s is empty", file)
 		}
 
-		for idx, row := range rows {
-			var sb strings.Builder
-			for i := 0; i < row_len; i++ {
-				if (i >= min) && (i <= max) {
-					if !config_item_exist {
-						if idx == 0 && i == min {
-							sb.WriteString("\"configuration_item\",")
-						} else if idx > 0 && i == min {
-							sb.WriteString("\"" + args.configuration_item + "\",")
-						}
-					}
-
-					if i >= len(row) {
-						sb.WriteString("")
-					} else {
-						// replace with supplied header
-						if idx == 0 && i > min {
-							if len(args.sheet_headers) > 0 && i <= len(args.sheet_headers) {
-								sb.WriteString("\"" + args.sheet_headers[i-1].(string) + "\"")
-							} else {
-								sb.WriteString("\"" + row[i] + "\"")
-							}
-						} else {
-							sb.WriteString("\"" + row[i] + "\"")
-						}
-					}
-					if (i < row_len-1) && (i < max) {
-						sb.WriteString(",")
-					}
-
-				}
+		var header []string
+		var records []map[string]string
+		var file_sources []string
+		if args.orientation == "vertical" {
+			table, err := csvToTable(s, args)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", file, err)
 			}
-
-			// only accept rows that are not empty
-			replacer := strings.NewReplacer(",", "", " ", "", "[]", "", "{}", "", "\"", "")
-			if replacer.Replace(sb.String()) != "" {
-				csv = append(csv, sb.String())
+			if table != nil {
+				header = table.header
+				records, _, file_sources = table.records()
 			}
-		}
-	} else {
-		// Get total number of columns
-		maxcol := 0
-		for _, row := range rows {
-			if len(row) > maxcol {
-				maxcol = len(row)
+		} else {
+			header, records, file_sources, err = readCSV(s, args)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", file, err)
 			}
 		}
-		var sb strings.Builder
-		sb.WriteString("\"configuration_item\",")
-		fieldcount := 0
-		for idx, row := range rows {
-			if strings.Trim(row[0], " ") != "" {
-				if idx == len(rows)-1 {
-					sb.WriteString("\"" + row[0] + "\"")
-				} else {
-					sb.WriteString("\"" + row[0] + "\",")
+
+		// the columns of the file must match the columns of the first file
+		if i == 0 {
+			columns = header
+		} else {
+			var missing, extra []string
+			for _, name := range columns {
+				if !stringInList(name, header) {
+					missing = append(missing, name)
 				}
-				fieldcount++
 			}
-		}
-		csv = append(csv, sb.String())
-		for i := 1; i < maxcol; i++ {
-			sb.Reset()
-			sb.WriteString("\"" + args.configuration_item + "\",")
-			for idx, row := range rows {
-				if i > len(row)-1 {
-					if idx < len(rows)-1 {
-						sb.WriteString("\"\",")
-					} else {
-						sb.WriteString("\"\"")
-					}
-				} else {
-					if idx < len(rows)-1 {
-						sb.WriteString("\"" + row[i] + "\",")
-					} else {
-						sb.WriteString("\"" + row[i] + "\"")
-					}
+			for _, name := range header {
+				if !stringInList(name, columns) {
+					extra = append(extra, name)
 				}
 			}
-			// only accept rows that are not empty
-			replacer := strings.NewReplacer(",", "", " ", "", "[]", "", "{}", "", "\"", "")
-			if replacer.Replace(sb.String()) != "" {
-				csv = append(csv, sb.String())
+			if len(missing) > 0 || len(extra) > 0 {
+				return nil, nil, fmt.Errorf("csv file %s does not have the columns of %s: missing [%s], extra [%s]", file, args.csv_files[0], strings.Join(missing, ","), strings.Join(extra, ","))
 			}
 		}
+
+		for r, record := range records {
+			if args.include_file_name {
+				record["_file"] = file
+			}
+			rows = append(rows, record)
+			sources = append(sources, fmt.Sprintf("%s %s", file, file_sources[r]))
+		}
 	}
-	if len(csv) == 1 {
-		return "", err
-	}
-	// return strings.Join(csv, "\n"), fmt.Errorf(fmt.Sprintf("%v", csv))
-	return strings.Join(csv, "\n"), err
+	return rows, sources, nil
 }
 
-func sliceIndex(arr []string, s string) int {
-	for i, v := range arr {
-		if v == s {
-			return i
+// Convert an Excel column name (A..XFD) to a zero-based column index
+func columnNameToIndex(name string) (int, error) {
+	col := strings.ToUpper(strings.TrimSpace(name))
+	if col == "" {
+		return -1, fmt.Errorf("column name is empty")
+	}
+	idx := 0
+	for _, r := range col {
+		if r < 'A' || r > 'Z' {
+			return -1, fmt.Errorf("\"%s\" is not a valid column name", name)
+		}
+		idx = idx*26 + int(r-'A'+1)
+		if idx > maxExcelColumns {
+			return -1, fmt.Errorf("column \"%s\" is beyond the last excel column XFD", name)
 		}
 	}
-	return -1
+	return idx - 1, nil
 }
 
 func getConfigurationItems(csv []map[string]string, configuration_item string) []string {
@@ -437,6 +1501,329 @@ func getItemData(csv []map[string]interface{}, items []string, configuration_ite
 	return string(j)
 }
 
+// Same as getItemData with the records of each configuration item in a map
+// by their key
+func getKeyedItemData(csv []map[string]interface{}, keys []string, items []string, configuration_item string) string {
+	mapitem := make(map[string]map[string]map[string]interface{})
+	for i, value := range csv {
+		item := configuration_item
+		if len(items) > 0 {
+			item = fmt.Sprintf("%v", value[configuration_item])
+			if !stringInList(item, items) {
+				continue
+			}
+		}
+		itemdata := make(map[string]interface{})
+		for k, v := range value {
+			if len(items) == 0 || k != configuration_item {
+				itemdata[k] = v
+			}
+		}
+		if mapitem[item] == nil {
+			mapitem[item] = make(map[string]map[string]interface{})
+		}
+		mapitem[item][keys[i]] = itemdata
+	}
+	j, _ := json.Marshal(mapitem)
+	return string(j)
+}
+
+// Get the key of each record from key_column. The keys must be unique for
+// each configuration item, the duplicates are reported with the worksheet row
+// or csv line of the records.
+func getRecordKeys(records []map[string]interface{}, positions []int, args *ConfigurationWorkbook) ([]string, error) {
+	keys := make([]string, len(records))
+	found := make(map[string][]string)
+	var order []string
+	for i, record := range records {
+		source := fmt.Sprintf("record %d", positions[i]+1)
+		if positions[i] < len(args.sources) && args.sources[positions[i]] != "" {
+			source = args.sources[positions[i]]
+		}
+		value, _ := recordValue(record, args.key_column, args.nest_separator)
+		switch v := value.(type) {
+		case string:
+			keys[i] = v
+		case float64:
+			keys[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case int:
+			keys[i] = strconv.Itoa(v)
+		case bool:
+			keys[i] = strconv.FormatBool(v)
+		case nil:
+		default:
+			return nil, fmt.Errorf("key_column \"%s\" must be a string, number or bool in %s", args.key_column, source)
+		}
+		if keys[i] == "" {
+			return nil, fmt.Errorf("key_column \"%s\" is empty in %s", args.key_column, source)
+		}
+		id := fmt.Sprintf("%v\x00%s", record[args.col_config_item], keys[i])
+		if _, ok := found[id]; !ok {
+			order = append(order, id)
+		}
+		found[id] = append(found[id], source)
+	}
+	var duplicates []string
+	for _, id := range order {
+		if len(found[id]) > 1 {
+			duplicates = append(duplicates, fmt.Sprintf("\"%s\" in %s", strings.SplitN(id, "\x00", 2)[1], strings.Join(found[id], ", ")))
+		}
+	}
+	if len(duplicates) > 0 {
+		return nil, fmt.Errorf("duplicate keys in key_column \"%s\": %s", args.key_column, strings.Join(duplicates, "; "))
+	}
+	return keys, nil
+}
+
+// Sort, dedupe and page the records. distinct_on, limit and offset apply to
+// the records of each configuration item. The position of each record in the
+// worksheet or csv is returned with the records.
+func selectRecords(records []map[string]interface{}, args *ConfigurationWorkbook) ([]map[string]interface{}, []int, error) {
+	var indexes []int
+	for i, record := range records {
+		if record != nil {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, nil, nil
+	}
+
+	// the columns must exist on at least one record
+	check := func(option string, names []string) error {
+		for _, name := range names {
+			found := false
+			for _, i := range indexes {
+				if _, ok := recordValue(records[i], name, args.nest_separator); ok {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("%s: column \"%s\" not found", option, name)
+			}
+		}
+		return nil
+	}
+	var order_names []string
+	for _, key := range args.order_by {
+		order_names = append(order_names, key["Name"].(string))
+	}
+	if err := check("order_by", order_names); err != nil {
+		return nil, nil, err
+	}
+	if err := check("distinct_on", args.distinct_on); err != nil {
+		return nil, nil, err
+	}
+	if err := check("columns", args.columns); err != nil {
+		return nil, nil, err
+	}
+	if err := check("exclude_columns", args.exclude_columns); err != nil {
+		return nil, nil, err
+	}
+	if args.key_column != "" {
+		if err := check("key_column", []string{args.key_column}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(args.order_by) > 0 {
+		sort.SliceStable(indexes, func(i, j int) bool {
+			for _, key := range args.order_by {
+				a, _ := recordValue(records[indexes[i]], key["Name"].(string), args.nest_separator)
+				b, _ := recordValue(records[indexes[j]], key["Name"].(string), args.nest_separator)
+				c := compareValues(a, b)
+				if c == 0 {
+					continue
+				}
+				if key["Descending"].(bool) {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+
+	var result []map[string]interface{}
+	var positions []int
+	seen := make(map[string]bool)
+	count := make(map[string]int)
+	for _, i := range indexes {
+		record := records[i]
+		item := fmt.Sprintf("%v", record[args.col_config_item])
+		if len(args.distinct_on) > 0 {
+			values := []interface{}{item}
+			for _, name := range args.distinct_on {
+				v, _ := recordValue(record, name, args.nest_separator)
+				values = append(values, v)
+			}
+			j, _ := json.Marshal(values)
+			if seen[string(j)] {
+				continue
+			}
+			seen[string(j)] = true
+		}
+		count[item]++
+		if count[item] <= args.offset || (args.limit > 0 && count[item] > args.offset+args.limit) {
+			continue
+		}
+		result = append(result, record)
+		positions = append(positions, i)
+	}
+	return result, positions, nil
+}
+
+// Keep only the attributes of columns or remove the attributes of
+// exclude_columns. The configuration item is always kept. With
+// nest_separator, the name can be the path of a nested attribute (ex.
+// network.ip), the other attributes of the nested object are kept or removed.
+func selectColumns(records []map[string]interface{}, args *ConfigurationWorkbook) []map[string]interface{} {
+	if len(args.columns) == 0 && len(args.exclude_columns) == 0 {
+		return records
+	}
+	path := func(record map[string]interface{}, name string) []string {
+		if _, ok := record[name]; ok || args.nest_separator == "" {
+			return []string{name}
+		}
+		return strings.Split(name, args.nest_separator)
+	}
+	result := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		if len(args.columns) > 0 {
+			result[i] = make(map[string]interface{})
+			if v, ok := record[args.col_config_item]; ok {
+				result[i][args.col_config_item] = v
+			}
+			for _, name := range args.columns {
+				keepPath(result[i], record, path(record, name))
+			}
+			continue
+		}
+		result[i] = record
+		for _, name := range args.exclude_columns {
+			if name != args.col_config_item {
+				result[i] = removePath(result[i], path(record, name))
+			}
+		}
+	}
+	return result
+}
+
+// Copy the attribute at the path of the source to the record. The nested
+// objects on the path are created in the record.
+func keepPath(record map[string]interface{}, source map[string]interface{}, path []string) {
+	v, ok := source[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		record[path[0]] = v
+		return
+	}
+	object, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+	child, ok := record[path[0]].(map[string]interface{})
+	if !ok {
+		child = make(map[string]interface{})
+		record[path[0]] = child
+	}
+	keepPath(child, object, path[1:])
+}
+
+// Get a copy of the record without the attribute at the path. The nested
+// objects on the path are copied, the record is not changed.
+func removePath(record map[string]interface{}, path []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(record))
+	for k, v := range record {
+		result[k] = v
+	}
+	if len(path) == 1 {
+		delete(result, path[0])
+	} else if object, ok := record[path[0]].(map[string]interface{}); ok {
+		result[path[0]] = removePath(object, path[1:])
+	}
+	return result
+}
+
+// Get the value of an attribute of the record. With nest_separator, the
+// name can be the path of a nested attribute (ex. network.ip).
+func recordValue(record map[string]interface{}, name string, separator string) (interface{}, bool) {
+	if v, ok := record[name]; ok {
+		return v, true
+	}
+	if separator == "" || !strings.Contains(name, separator) {
+		return nil, false
+	}
+	var value interface{} = record
+	for _, part := range strings.Split(name, separator) {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Compare two attribute values. Numbers and booleans are compared by value,
+// strings by text. Empty values sort first, then booleans, numbers, strings
+// and other values.
+func compareValues(a interface{}, b interface{}) int {
+	rank := func(v interface{}) int {
+		switch x := v.(type) {
+		case nil:
+			return 0
+		case string:
+			if x == "" {
+				return 0
+			}
+			return 3
+		case bool:
+			return 1
+		case float64, int:
+			return 2
+		}
+		return 4
+	}
+	ra, rb := rank(a), rank(b)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+	switch ra {
+	case 0:
+		return 0
+	case 1:
+		if a.(bool) == b.(bool) {
+			return 0
+		} else if !a.(bool) {
+			return -1
+		}
+		return 1
+	case 2:
+		na, _ := filterNumber(a)
+		nb, _ := filterNumber(b)
+		if na < nb {
+			return -1
+		} else if na > nb {
+			return 1
+		}
+		return 0
+	case 3:
+		return strings.Compare(a.(string), b.(string))
+	}
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	return strings.Compare(string(ja), string(jb))
+}
+
 func unique(items []string) []string {
 	keys := make(map[string]bool)
 	list := []string{}
@@ -449,7 +1836,7 @@ func unique(items []string) []string {
 	return list
 }
 
-func reMapData(args *ConfigurationWorkbook) []map[string]interface{} {
+func reMapData(args *ConfigurationWorkbook) ([]map[string]interface{}, error) {
 	new_csv := make([]map[string]interface{}, len(args.csv))
 	for key, value := range args.csv {
 		item_key := ""
@@ -459,9 +1846,11 @@ func reMapData(args *ConfigurationWorkbook) []map[string]interface{} {
 			}
 		}
 		new_value := make(map[string]interface{})
+		empty_values := make(map[string]bool)
 		new_tag := make(map[string]string)
 		tags := make(map[string]string)
-		include_value := false
+		columns := make(map[string]string)
+		texts := make(map[string]string)
 		var new_key, new_type string
 		for k, v := range value {
 			_ = v
@@ -553,14 +1942,29 @@ func reMapData(args *ConfigurationWorkbook) []map[string]interface{} {
 			} else {
 				new_key = k
 				new_value[k] = value[k]
+				// use the native value of the excel cell
+				if key < len(args.values) && !(args.lookup != nil && checkLookupValue(args.lookup, k)) {
+					if v, ok := args.values[key][k]; ok {
+						new_value[k] = v
+					}
+				}
+			}
+
+			if k != args.configuration_item && value[k] == "" {
+				empty_values[new_key] = true
 			}
+			if k == args.configuration_item {
+				new_key = k
+			}
+			columns[k] = new_key
+			texts[new_key] = value[k]
 
 			// get lookup value
 			if args.lookup != nil && checkLookupValue(args.lookup, new_key) {
 				if strings.Contains(value[new_key], ",") {
 					lkvals := strings.Split(value[new_key], ",")
 					for idx, vl := range lkvals {
-						lookup_value, err := getLookupValue(args.lookup, args.excel_file, args.excel_pass, args.sheet_name, new_key, vl)
+						lookup_value, err := getLookupValue(args.lookup, args.excel_file, args.excel_data, args.excel_pass, args.sheet_name, new_key, vl)
 						if err == nil && lookup_value != "" {
 							if idx == 0 {
 								new_value[new_key] = lookup_value
@@ -570,34 +1974,319 @@ func reMapData(args *ConfigurationWorkbook) []map[string]interface{} {
 						}
 					}
 				} else {
-					lookup_value, err := getLookupValue(args.lookup, args.excel_file, args.excel_pass, args.sheet_name, new_key, value[new_key])
+					lookup_value, err := getLookupValue(args.lookup, args.excel_file, args.excel_data, args.excel_pass, args.sheet_name, new_key, value[new_key])
 					if err == nil && lookup_value != "" {
 						new_value[new_key] = lookup_value
 					}
 				}
 			}
+		}
+		for k, v := range new_tag {
+			tags[k] = v
+		}
 
-			// check if value included in filter
-			if len(args.filters) > 0 {
-				if !include_value {
-					include_value = checkFiltersForItem(args.filters, k, value[k])
+		// check if the record is included by the filters
+		include_value := true
+		if len(args.filters) > 0 {
+			include_value = checkFilters(args.filters, args.filter_match, func(name string) (interface{}, string) {
+				if _, ok := new_value[name]; !ok {
+					if _, ok := new_tag[name]; !ok {
+						if c, ok := columns[name]; ok {
+							name = c
+						}
+					}
 				}
-				if !include_value {
-					include_value = checkFiltersForItem(args.filters, new_key, value[k])
+				if v, ok := new_value[name]; ok {
+					return v, texts[name]
 				}
-			} else {
-				include_value = true
-			}
+				if v, ok := new_tag[name]; ok {
+					return v, v
+				}
+				if v, ok := new_tag[strings.Title(name)]; ok {
+					return v, v
+				}
+				return nil, ""
+			})
 		}
-		for k, v := range new_tag {
-			tags[k] = v
+		if args.nest_separator != "" {
+			var err error
+			new_value, err = nestAttributes(new_value, empty_values, args.nest_separator, args.col_config_item)
+			if err != nil {
+				return nil, err
+			}
 		}
 		if include_value {
 			new_value["tags"] = tags
 			new_csv[key] = new_value
 		}
 	}
-	return new_csv
+	return new_csv, nil
+}
+
+// Add the rows of the child sources to the records. The child rows having the
+// child_key value equal to the parent_key value of a record are remapped like
+// the records and added as a list attribute.
+func addChildren(records []map[string]interface{}, args *ConfigurationWorkbook) error {
+	for _, child := range args.children {
+		attribute := child["Attribute"].(string)
+		parent_key := child["ParentKey"].(string)
+		child_key := child["ChildKey"].(string)
+		if len(args.csv) > 0 {
+			if _, ok := args.csv[0][parent_key]; !ok {
+				return fmt.Errorf("child \"%s\" parent_key column \"%s\" not found", attribute, parent_key)
+			}
+		}
+
+		child_args, err := readChildRows(child, args)
+		if err != nil {
+			return fmt.Errorf("child \"%s\": %v", attribute, err)
+		}
+		if len(child_args.csv) > 0 {
+			if _, ok := child_args.csv[0][child_key]; !ok {
+				return fmt.Errorf("child \"%s\" child_key column \"%s\" not found", attribute, child_key)
+			}
+		}
+		child_records, err := reMapData(child_args)
+		if err != nil {
+			return fmt.Errorf("child \"%s\": %v", attribute, err)
+		}
+
+		// group the child rows by key in the order of the child source
+		rows := make(map[string][]map[string]interface{})
+		for i, record := range child_records {
+			delete(record, args.col_config_item)
+			key := child_args.csv[i][child_key]
+			rows[key] = append(rows[key], record)
+		}
+		for i, record := range records {
+			if record == nil {
+				continue
+			}
+			if _, ok := record[attribute]; ok {
+				return fmt.Errorf("child attribute \"%s\" is already a column of the records", attribute)
+			}
+			list := rows[args.csv[i][parent_key]]
+			if list == nil {
+				list = []map[string]interface{}{}
+			}
+			record[attribute] = list
+		}
+	}
+	return nil
+}
+
+// Read the rows of a child worksheet or csv. The worksheet is read from the
+// workbook of the data source unless the child has its own excel, with the
+// options of the data source except the row and column windows.
+func readChildRows(child map[string]interface{}, args *ConfigurationWorkbook) (*ConfigurationWorkbook, error) {
+	child_args := *args
+	child_args.sheet_name = child["Worksheet"].(string)
+	child_args.table = ""
+	child_args.defined_name = ""
+	child_args.cell_range = ""
+	child_args.header_row = 0
+	child_args.first_data_row = 0
+	child_args.last_data_row = 0
+	child_args.detect_header = false
+	child_args.start_column = ""
+	child_args.end_column = ""
+	child_args.sheet_headers = nil
+	child_args.orientation = "horizontal"
+	child_args.include_sheet_name = false
+	child_args.style_filters = nil
+	child_args.filters = nil
+	child_args.children = nil
+	child_args.csv = nil
+	child_args.values = nil
+
+	if csv := child["Csv"].(string); csv != "" {
+		rows, err := stringToMap(csv, &child_args)
+		if err != nil {
+			return nil, err
+		}
+		child_args.csv = rows
+		return &child_args, nil
+	}
+
+	if excel := child["Excel"].(string); excel != "" {
+		child_args.excel_file = excel
+		child_args.excel_data = nil
+		child_args.excel_pass = ""
+		data, err := convertODS(excel, nil)
+		if err != nil {
+			return nil, err
+		}
+		child_args.excel_data = data
+	}
+	f, err := openWorkbook(child_args.excel_file, child_args.excel_data, child_args.excel_pass)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	table, err := excelToTable(f, &child_args)
+	if err != nil || table == nil {
+		return &child_args, err
+	}
+	rows, values, _ := table.records()
+	child_args.csv = rows
+	if args.native_types {
+		child_args.values = values
+	}
+	return &child_args, nil
+}
+
+// A step of the path of a nested attribute, a field name or a list index
+type nestStep struct {
+	name  string
+	index int
+}
+
+// A node of the nested attributes. The node is a value, an object or a list
+// and is empty if all its values come from empty cells.
+type nestNode struct {
+	column string
+	value  interface{}
+	leaf   bool
+	fields map[string]*nestNode
+	items  map[int]*nestNode
+	empty  bool
+}
+
+var nestIndexPattern = regexp.MustCompile(`\[(\d+)\]$`)
+
+// Split the attribute name on the separator. An index at the end of a part
+// (ex. disks[0]) is a list index. A name without separator or index
+// (including an empty name) is not nested.
+func nestPath(name string, separator string) ([]nestStep, error) {
+	if !strings.Contains(name, separator) && !nestIndexPattern.MatchString(name) {
+		return []nestStep{{name: name, index: -1}}, nil
+	}
+	var path []nestStep
+	for _, part := range strings.Split(name, separator) {
+		var indexes []nestStep
+		for {
+			m := nestIndexPattern.FindStringSubmatchIndex(part)
+			if m == nil {
+				break
+			}
+			index, err := strconv.Atoi(part[m[2]:m[3]])
+			if err != nil || index > 100000 {
+				return nil, fmt.Errorf("invalid list index in column \"%s\"", name)
+			}
+			indexes = append([]nestStep{{index: index}}, indexes...)
+			part = part[:m[0]]
+		}
+		if part == "" && (len(indexes) == 0 || len(path) == 0) {
+			return nil, fmt.Errorf("invalid nested column \"%s\"", name)
+		}
+		if part != "" {
+			path = append(path, nestStep{name: part, index: -1})
+		}
+		path = append(path, indexes...)
+	}
+	return path, nil
+}
+
+// Nest the attributes having the separator or a list index in their name.
+// network.ip becomes {"network": {"ip": ...}} and disks[0].size becomes
+// {"disks": [{"size": ...}]}. List items having only empty cells are left
+// out. The tags and the configuration item column are not nested.
+func nestAttributes(record map[string]interface{}, empty_values map[string]bool, separator string, col_config_item string) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	root := &nestNode{fields: make(map[string]*nestNode)}
+	for _, k := range keys {
+		if k == "tags" || k == col_config_item {
+			root.fields[k] = &nestNode{column: k, value: record[k], leaf: true}
+			continue
+		}
+		path, err := nestPath(k, separator)
+		if err != nil {
+			return nil, err
+		}
+		node := root
+		for i, step := range path {
+			if node.leaf || (step.index < 0 && node.items != nil) || (step.index >= 0 && node.fields != nil) {
+				return nil, fmt.Errorf("column \"%s\" conflicts with column \"%s\"", k, node.column)
+			}
+			var child *nestNode
+			if step.index < 0 {
+				if node.fields == nil {
+					node.fields = make(map[string]*nestNode)
+				}
+				if child = node.fields[step.name]; child == nil {
+					child = &nestNode{column: k}
+					node.fields[step.name] = child
+				}
+			} else {
+				if node.items == nil {
+					node.items = make(map[int]*nestNode)
+				}
+				if child = node.items[step.index]; child == nil {
+					child = &nestNode{column: k}
+					node.items[step.index] = child
+				}
+			}
+			if i == len(path)-1 {
+				if child.leaf || child.fields != nil || child.items != nil {
+					return nil, fmt.Errorf("column \"%s\" conflicts with column \"%s\"", k, child.column)
+				}
+				child.leaf = true
+				child.value = record[k]
+				child.empty = empty_values[k]
+			}
+			node = child
+		}
+	}
+	return root.build().(map[string]interface{}), nil
+}
+
+// Build the value of the node, the empty items of the lists are left out
+func (n *nestNode) build() interface{} {
+	if n.leaf {
+		return n.value
+	}
+	if n.items != nil {
+		indexes := make([]int, 0, len(n.items))
+		for i := range n.items {
+			indexes = append(indexes, i)
+		}
+		sort.Ints(indexes)
+		list := []interface{}{}
+		for _, i := range indexes {
+			if !n.items[i].isEmpty() {
+				list = append(list, n.items[i].build())
+			}
+		}
+		return list
+	}
+	object := make(map[string]interface{})
+	for name, child := range n.fields {
+		object[name] = child.build()
+	}
+	return object
+}
+
+// Check if all the values of the node come from empty cells
+func (n *nestNode) isEmpty() bool {
+	if n.leaf {
+		return n.empty
+	}
+	for _, child := range n.fields {
+		if !child.isEmpty() {
+			return false
+		}
+	}
+	for _, child := range n.items {
+		if !child.isEmpty() {
+			return false
+		}
+	}
+	return true
 }
 
 func getMapValue(config interface{}, config_item string, config_key string) (string, string) {
@@ -660,11 +2349,11 @@ func createDefaultMapping(items []string, csv []map[string]string, configuration
 }
 
 // Remove row that contains empty or row with non printer characters and invisible characters (white spaces)
-func delete_empty_row(s [][]string) [][]string {
-	var r [][]string
+func delete_empty_row(s [][]excelCell) [][]excelCell {
+	var r [][]excelCell
 	for _, str := range s {
 		if len(str) != 0 {
-			if is_printable(str) {
+			if is_printable(cellTexts(str)) {
 				r = append(r, str)
 			}
 		}
@@ -0,0 +1,355 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnNameToIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		err   string
+	}{
+		{name: "A", index: 0},
+		{name: "Z", index: 25},
+		{name: "AA", index: 26},
+		{name: "XFD", index: 16383},
+		{name: "xfd", index: 16383},
+		{name: " b ", index: 1},
+		{name: "XFE", err: "beyond the last excel column"},
+		{name: "A1", err: "not a valid column name"},
+		{name: "", err: "column name is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, err := columnNameToIndex(tt.name)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if index != tt.index {
+				t.Fatalf("expected %d, got %d", tt.index, index)
+			}
+		})
+	}
+}
+
+func TestNestPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path []nestStep
+		err  string
+	}{
+		{name: "", path: []nestStep{{name: "", index: -1}}},
+		{name: "name", path: []nestStep{{name: "name", index: -1}}},
+		{name: "network.ip", path: []nestStep{{name: "network", index: -1}, {name: "ip", index: -1}}},
+		{name: "disks[0]", path: []nestStep{{name: "disks", index: -1}, {index: 0}}},
+		{name: "disks[1].size", path: []nestStep{{name: "disks", index: -1}, {index: 1}, {name: "size", index: -1}}},
+		{name: "grid[0][2]", path: []nestStep{{name: "grid", index: -1}, {index: 0}, {index: 2}}},
+		{name: "network.", err: "invalid nested column"},
+		{name: ".ip", err: "invalid nested column"},
+		{name: "[0]", err: "invalid nested column"},
+		{name: "disks[100001]", err: "invalid list index"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := nestPath(tt.name, ".")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(path, tt.path) {
+				t.Fatalf("expected %v, got %v", tt.path, path)
+			}
+		})
+	}
+}
+
+func TestNestAttributes(t *testing.T) {
+	tests := []struct {
+		name   string
+		record map[string]interface{}
+		empty  map[string]bool
+		result string
+		err    string
+	}{
+		{
+			name:   "objects",
+			record: map[string]interface{}{"configuration_item": "vm", "name": "web", "network.ip": "10.0.0.1", "network.vlan.id": 7, "tags": map[string]interface{}{}},
+			result: `{"configuration_item":"vm","name":"web","network":{"ip":"10.0.0.1","vlan":{"id":7}},"tags":{}}`,
+		},
+		{
+			name:   "lists",
+			record: map[string]interface{}{"disks[1].size": 20, "disks[0].size": 10, "ports[0]": 80, "ports[2]": 443},
+			result: `{"disks":[{"size":10},{"size":20}],"ports":[80,443]}`,
+		},
+		{
+			name:   "empty list items",
+			record: map[string]interface{}{"disks[0].size": 10, "disks[1].size": "", "disks[1].type": ""},
+			empty:  map[string]bool{"disks[1].size": true, "disks[1].type": true},
+			result: `{"disks":[{"size":10}]}`,
+		},
+		{
+			name:   "empty column name",
+			record: map[string]interface{}{"": "", "network.ip": "10.0.0.1"},
+			result: `{"":"","network":{"ip":"10.0.0.1"}}`,
+		},
+		{
+			name:   "value and object",
+			record: map[string]interface{}{"network": "lan", "network.ip": "10.0.0.1"},
+			err:    `column "network.ip" conflicts with column "network"`,
+		},
+		{
+			name:   "object and list",
+			record: map[string]interface{}{"disks.size": 10, "disks[0].size": 20},
+			err:    `column "disks[0].size" conflicts with column "disks.size"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := nestAttributes(tt.record, tt.empty, ".", "configuration_item")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			j, _ := json.Marshal(result)
+			if string(j) != tt.result {
+				t.Fatalf("expected %s, got %s", tt.result, j)
+			}
+		})
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a      interface{}
+		b      interface{}
+		result int
+	}{
+		{a: nil, b: "", result: 0},
+		{a: "", b: false, result: -1},
+		{a: false, b: true, result: -1},
+		{a: true, b: true, result: 0},
+		{a: true, b: 0.0, result: -1},
+		{a: 2.0, b: 10.0, result: -1},
+		{a: 10, b: 2.0, result: 1},
+		{a: 2, b: 2.0, result: 0},
+		{a: 100.0, b: "10", result: -1},
+		{a: "b", b: "a", result: 1},
+		{a: "web10", b: "web2", result: -1},
+		{a: "z", b: []interface{}{"a"}, result: -1},
+		{a: map[string]interface{}{"a": 1}, b: map[string]interface{}{"a": 2}, result: -1},
+	}
+	for _, tt := range tests {
+		if result := compareValues(tt.a, tt.b); result != tt.result {
+			t.Fatalf("compareValues(%#v, %#v): expected %d, got %d", tt.a, tt.b, tt.result, result)
+		}
+		if result := compareValues(tt.b, tt.a); result != -tt.result {
+			t.Fatalf("compareValues(%#v, %#v): expected %d, got %d", tt.b, tt.a, -tt.result, result)
+		}
+	}
+}
+
+func TestSelectRecords(t *testing.T) {
+	record := func(item string, name string, env string, cpu float64) map[string]interface{} {
+		return map[string]interface{}{"configuration_item": item, "name": name, "env": env, "cpu": cpu, "network": map[string]interface{}{"ip": "10.0.0." + name[len(name)-1:]}}
+	}
+	records := []map[string]interface{}{
+		record("vm", "web1", "prod", 2),
+		nil,
+		record("vm", "web2", "dev", 8),
+		record("db", "db1", "prod", 4),
+		record("vm", "web3", "prod", 4),
+		record("db", "db2", "prod", 16),
+		record("vm", "web4", "dev", 8),
+	}
+	order := func(name string, descending bool) map[string]interface{} {
+		return map[string]interface{}{"Name": name, "Descending": descending}
+	}
+	tests := []struct {
+		name      string
+		args      ConfigurationWorkbook
+		names     []string
+		positions []int
+		err       string
+	}{
+		{name: "no options", names: []string{"web1", "web2", "db1", "web3", "db2", "web4"}, positions: []int{0, 2, 3, 4, 5, 6}},
+		{name: "order_by", args: ConfigurationWorkbook{order_by: []map[string]interface{}{order("cpu", true), order("name", false)}}, names: []string{"db2", "web2", "web4", "db1", "web3", "web1"}, positions: []int{5, 2, 6, 3, 4, 0}},
+		{name: "nested order_by", args: ConfigurationWorkbook{order_by: []map[string]interface{}{order("network.ip", true)}, nest_separator: "."}, names: []string{"web4", "web3", "web2", "db2", "web1", "db1"}, positions: []int{6, 4, 2, 5, 0, 3}},
+		{name: "limit per item", args: ConfigurationWorkbook{limit: 1}, names: []string{"web1", "db1"}, positions: []int{0, 3}},
+		{name: "offset per item", args: ConfigurationWorkbook{offset: 1, limit: 2}, names: []string{"web2", "web3", "db2"}, positions: []int{2, 4, 5}},
+		{name: "distinct per item", args: ConfigurationWorkbook{distinct_on: []string{"env"}}, names: []string{"web1", "web2", "db1"}, positions: []int{0, 2, 3}},
+		{name: "distinct then limit", args: ConfigurationWorkbook{distinct_on: []string{"cpu"}, order_by: []map[string]interface{}{order("cpu", true)}, limit: 2}, names: []string{"db2", "web2", "db1", "web3"}, positions: []int{5, 2, 3, 4}},
+		{name: "missing order_by", args: ConfigurationWorkbook{order_by: []map[string]interface{}{order("ram", false)}}, err: `order_by: column "ram" not found`},
+		{name: "missing columns", args: ConfigurationWorkbook{columns: []string{"network.mask"}, nest_separator: "."}, err: `columns: column "network.mask" not found`},
+		{name: "missing key_column", args: ConfigurationWorkbook{key_column: "id"}, err: `key_column: column "id" not found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.col_config_item = "configuration_item"
+			result, positions, err := selectRecords(records, &args)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, r := range result {
+				names = append(names, r["name"].(string))
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Fatalf("expected %v, got %v", tt.names, names)
+			}
+			if !reflect.DeepEqual(positions, tt.positions) {
+				t.Fatalf("expected positions %v, got %v", tt.positions, positions)
+			}
+		})
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	records := []map[string]interface{}{
+		{"configuration_item": "vm", "name": "web1", "network.ip": "flat", "network": map[string]interface{}{"ip": "10.0.0.1", "vlan": map[string]interface{}{"id": 7.0}}},
+	}
+	tests := []struct {
+		name   string
+		args   ConfigurationWorkbook
+		result string
+	}{
+		{name: "columns", args: ConfigurationWorkbook{columns: []string{"name"}}, result: `{"configuration_item":"vm","name":"web1"}`},
+		{name: "literal name first", args: ConfigurationWorkbook{columns: []string{"network.ip"}, nest_separator: "."}, result: `{"configuration_item":"vm","network.ip":"flat"}`},
+		{name: "nested columns", args: ConfigurationWorkbook{columns: []string{"network.vlan.id"}, nest_separator: "."}, result: `{"configuration_item":"vm","network":{"vlan":{"id":7}}}`},
+		{name: "exclude", args: ConfigurationWorkbook{exclude_columns: []string{"network", "configuration_item"}}, result: `{"configuration_item":"vm","name":"web1","network.ip":"flat"}`},
+		{name: "nested exclude", args: ConfigurationWorkbook{exclude_columns: []string{"network.vlan.id"}, nest_separator: "."}, result: `{"configuration_item":"vm","name":"web1","network":{"ip":"10.0.0.1","vlan":{}},"network.ip":"flat"}`},
+	}
+	original, _ := json.Marshal(records)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			args.col_config_item = "configuration_item"
+			result, _ := json.Marshal(selectColumns(records, &args)[0])
+			if string(result) != tt.result {
+				t.Fatalf("expected %s, got %s", tt.result, result)
+			}
+			// the records are not changed
+			if j, _ := json.Marshal(records); string(j) != string(original) {
+				t.Fatalf("records changed to %s", j)
+			}
+		})
+	}
+}
+
+func TestGetRecordKeys(t *testing.T) {
+	record := func(item string, name interface{}) map[string]interface{} {
+		return map[string]interface{}{"configuration_item": item, "name": name, "network": map[string]interface{}{"id": name}}
+	}
+	tests := []struct {
+		name      string
+		records   []map[string]interface{}
+		positions []int
+		sources   []string
+		separator string
+		column    string
+		keys      []string
+		err       string
+	}{
+		{
+			name:      "typed keys",
+			records:   []map[string]interface{}{record("vm", "web1"), record("vm", 2.5), record("vm", 3), record("vm", true)},
+			positions: []int{0, 1, 2, 3},
+			keys:      []string{"web1", "2.5", "3", "true"},
+		},
+		{
+			name:      "same key on several items",
+			records:   []map[string]interface{}{record("vm", "web1"), record("db", "web1")},
+			positions: []int{0, 1},
+			keys:      []string{"web1", "web1"},
+		},
+		{
+			name:      "nested key",
+			records:   []map[string]interface{}{record("vm", "web1"), record("vm", "web2")},
+			positions: []int{0, 1},
+			separator: ".",
+			column:    "network.id",
+			keys:      []string{"web1", "web2"},
+		},
+		{
+			name:      "duplicates with rows",
+			records:   []map[string]interface{}{record("vm", "web"), record("vm", "db"), record("vm", "web"), record("vm", "db"), record("vm", "web")},
+			positions: []int{0, 2, 4, 5, 6},
+			sources:   []string{"row 3", "row 4", "row 5", "row 6", "row 7", "row 8", "row 9"},
+			err:       `duplicate keys in key_column "name": "web" in row 3, row 7, row 9; "db" in row 5, row 8`,
+		},
+		{
+			name:      "duplicates without sources",
+			records:   []map[string]interface{}{record("vm", "web"), record("vm", "web")},
+			positions: []int{1, 4},
+			err:       `duplicate keys in key_column "name": "web" in record 2, record 5`,
+		},
+		{
+			name:      "empty key",
+			records:   []map[string]interface{}{record("vm", "web"), record("vm", "")},
+			positions: []int{0, 1},
+			sources:   []string{"line 2", "line 3"},
+			err:       `key_column "name" is empty in line 3`,
+		},
+		{
+			name:      "missing key",
+			records:   []map[string]interface{}{{"configuration_item": "vm"}},
+			positions: []int{0},
+			sources:   []string{`worksheet "servers" row 2`},
+			err:       `key_column "name" is empty in worksheet "servers" row 2`,
+		},
+		{
+			name:      "list key",
+			records:   []map[string]interface{}{record("vm", []interface{}{"a"})},
+			positions: []int{0},
+			sources:   []string{"column B"},
+			err:       `key_column "name" must be a string, number or bool in column B`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := &ConfigurationWorkbook{key_column: "name", col_config_item: "configuration_item", nest_separator: tt.separator, sources: tt.sources}
+			if tt.column != "" {
+				args.key_column = tt.column
+			}
+			keys, err := getRecordKeys(tt.records, tt.positions, args)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Fatalf("expected %v, got %v", tt.keys, keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,1245 @@
+package config
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// A rectangular block of cells on a worksheet. Row and column indexes are
+// zero-based and inclusive. A negative end_row means up to the last row.
+type excelArea struct {
+	sheet     string
+	start_col int
+	end_col   int
+	start_row int
+	end_row   int
+}
+
+// A worksheet cell. The text is the value displayed by excel and the value is
+// the native value of the cell (string, float64, bool or RFC3339 date), nil if
+// native types are not used. The row and column are the 1-based position of
+// the cell in the worksheet, or the line and field of a csv.
+type excelCell struct {
+	text       string
+	value      interface{}
+	merged     string
+	hidden_row bool
+	hidden_col bool
+	style      int
+	row        int
+	col        int
+}
+
+func cellTexts(row []excelCell) []string {
+	texts := make([]string, len(row))
+	for i, cell := range row {
+		texts[i] = cell.text
+	}
+	return texts
+}
+
+// Open the workbook from the excel file or from the content of the workbook.
+// OpenDocument spreadsheets are converted to an excel workbook.
+func openWorkbook(excel_file string, excel_data []byte, password string) (*excelize.File, error) {
+	data, err := convertODS(excel_file, excel_data)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		excel_data = data
+	}
+	if len(excel_data) > 0 {
+		return excelize.OpenReader(bytes.NewReader(excel_data), excelize.Options{Password: password})
+	}
+	return excelize.OpenFile(excel_file, excelize.Options{Password: password})
+}
+
+// Get the areas of the excel table, defined name or range of the workbook. No
+// area is returned when the whole worksheet is read.
+func getWorkbookAreas(f *excelize.File, args *ConfigurationWorkbook) ([]excelArea, error) {
+	if args.table != "" {
+		area, err := getTableArea(f, args.table, args.sheet_name)
+		if err != nil {
+			return nil, err
+		}
+		return []excelArea{area}, nil
+	}
+	if args.defined_name != "" {
+		areas, err := getDefinedNameAreas(f, args.defined_name, args.sheet_name)
+		if err == nil && len(areas) == 0 {
+			err = fmt.Errorf("%s does not have data", describeWorkbookSource(args))
+		}
+		return areas, err
+	}
+
+	// check if sheet is existing in the workbook
+	if err := checkSheetExists(f, args.sheet_name); err != nil {
+		return nil, err
+	}
+	if !args.include_hidden_sheets {
+		visible, err := f.GetSheetVisible(args.sheet_name)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, fmt.Errorf("worksheet \"%s\" is hidden, set include_hidden_sheets to read it", args.sheet_name)
+		}
+	}
+	if args.cell_range != "" {
+		area, err := parseAreaReference(args.cell_range)
+		if err != nil {
+			return nil, err
+		}
+		area.sheet = args.sheet_name
+		return []excelArea{area}, nil
+	}
+	return nil, nil
+}
+
+// Get the rows of the areas of the workbook or of the worksheet
+func getWorkbookRows(f *excelize.File, areas []excelArea, args *ConfigurationWorkbook) ([][]excelCell, error) {
+	if len(areas) > 0 {
+		return getAreaRows(f, areas, args)
+	}
+	rows, err := getSheetRows(f, args.sheet_name, args.last_data_row, args)
+	if err != nil {
+		return nil, err
+	}
+	return applyRowWindow(rows, args)
+}
+
+// Reader of the rows of a worksheet up to last_row (all rows if 0). The rows
+// are read with the streaming rows iterator, the cell types, styles and layout
+// are read from the worksheet xml along the rows. Number formats are not
+// applied if value_format is raw.
+type sheetReader struct {
+	f          *excelize.File
+	sheet      string
+	last_row   int
+	args       *ConfigurationWorkbook
+	iter       *excelize.Rows
+	scanner    *sheetScanner
+	date1904   bool
+	dateStyles map[int]bool
+	count      int
+	// the current row, its 1-based number and hidden flag
+	cells  []excelCell
+	row    int
+	hidden bool
+}
+
+func openSheetReader(f *excelize.File, sheet string, last_row int, args *ConfigurationWorkbook) (*sheetReader, error) {
+	iter, err := f.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	scanner, err := openSheetScanner(f, sheet, args)
+	if err != nil {
+		iter.Close()
+		return nil, err
+	}
+	s := &sheetReader{f: f, sheet: sheet, last_row: last_row, args: args, iter: iter, scanner: scanner, dateStyles: make(map[int]bool)}
+	if props, err := f.GetWorkbookProps(); err == nil && props.Date1904 != nil {
+		s.date1904 = *props.Date1904
+	}
+	return s, nil
+}
+
+func (s *sheetReader) close() {
+	s.scanner.close()
+	s.iter.Close()
+}
+
+// Read the next row of the worksheet, false is returned after the last row
+func (s *sheetReader) next() (bool, error) {
+	if (s.last_row > 0 && s.row >= s.last_row) || !s.iter.Next() {
+		return false, s.iter.Error()
+	}
+	s.row++
+	texts, err := s.iter.Columns(excelize.Options{RawCellValue: s.args.value_format == "raw"})
+	if err != nil {
+		return false, err
+	}
+	meta, err := s.scanner.getRow(s.row)
+	if err != nil {
+		return false, fmt.Errorf("worksheet \"%s\": %v", s.sheet, err)
+	}
+	s.hidden = meta.hidden && !s.args.include_hidden_rows
+	s.cells = make([]excelCell, len(texts))
+	for c, text := range texts {
+		cell := excelCell{text: text, row: s.row, col: c + 1, hidden_row: s.hidden}
+		cell.hidden_col = s.scanner.hidden_cols[c] && !s.args.include_hidden_columns
+		x, ok := meta.cells[c]
+		if ok {
+			cell.style = x.S
+		}
+		if s.args.native_types {
+			cell.value = text
+			if ok && text != "" {
+				cell.value = nativeValue(s.f, x, text, s.date1904, s.dateStyles)
+			}
+		}
+		s.cells[c] = cell
+	}
+
+	if len(texts) > 0 {
+		s.count++
+		if s.args.max_rows > 0 && s.count > s.args.max_rows {
+			return false, fmt.Errorf("worksheet \"%s\" has more than %d rows (max_rows), use a range or increase max_rows", s.sheet, s.args.max_rows)
+		}
+	}
+	return true, nil
+}
+
+// Get the rows of the worksheet up to last_row (all rows if 0). Formula cells
+// have the value cached in the workbook, the calculated value or the formula
+// text depending on formulas. Merged cells are filled or flagged depending on
+// merged_cells.
+func getSheetRows(f *excelize.File, sheet string, last_row int, args *ConfigurationWorkbook) ([][]excelCell, error) {
+	reader, err := openSheetReader(f, sheet, last_row, args)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.close()
+
+	hidden_rows := make(map[int]bool)
+	var rows [][]excelCell
+	for {
+		ok, err := reader.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		hidden_rows[reader.row-1] = reader.hidden
+		rows = append(rows, reader.cells)
+	}
+
+	if args.formulas == "calculate" || args.formulas == "raw" {
+		rows, err = applyFormulas(f, sheet, rows, reader.date1904, reader.dateStyles, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if args.merged_cells != "first" {
+		merges, err := reader.scanner.getMergeCells()
+		if err != nil {
+			return nil, fmt.Errorf("worksheet \"%s\": %v", sheet, err)
+		}
+		rows, err = applyMergedCells(sheet, rows, merges, args)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if args.formulas != "raw" {
+		var cells []string
+		for _, row := range rows {
+			cells = appendFormulaErrors(cells, row)
+		}
+		reportFormulaErrors(sheet, cells, args)
+	}
+	// cells added by formulas and merged ranges are flagged as well
+	for r := range rows {
+		for c := range rows[r] {
+			rows[r][c].hidden_row = hidden_rows[r]
+			rows[r][c].hidden_col = reader.scanner.hidden_cols[c] && !args.include_hidden_columns
+		}
+	}
+	return rows, nil
+}
+
+// Get the native value of a cell from the cell type and number format
+func nativeValue(f *excelize.File, cell xmlCell, text string, date1904 bool, dateStyles map[int]bool) interface{} {
+	switch cell.T {
+	case "b":
+		return cell.V == "1" || strings.EqualFold(cell.V, "true")
+	case "d":
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, cell.V); err == nil {
+				return t.Format(time.RFC3339)
+			}
+		}
+	case "", "n":
+		n, err := strconv.ParseFloat(cell.V, 64)
+		if err != nil {
+			return text
+		}
+		if cell.S == 0 {
+			return n
+		}
+		isDate, ok := dateStyles[cell.S]
+		if !ok {
+			if style, err := getCellStyle(f, cell.S); err == nil {
+				isDate = isDateNumFmt(style)
+			}
+			dateStyles[cell.S] = isDate
+		}
+		if isDate {
+			if t, err := excelize.ExcelDateToTime(n, date1904); err == nil {
+				return t.Format(time.RFC3339)
+			}
+		}
+		return n
+	}
+	return text
+}
+
+// A cell of the worksheet xml. The value is the raw value of the cell, the
+// index of the shared string for string cells.
+type xmlCell struct {
+	R string    `xml:"r,attr"`
+	S int       `xml:"s,attr"`
+	T string    `xml:"t,attr"`
+	F *struct{} `xml:"f"`
+	V string    `xml:"v"`
+}
+
+// A row of the worksheet xml, the cells are keyed by zero-based column
+type xmlRow struct {
+	num    int
+	hidden bool
+	cells  map[int]xmlCell
+}
+
+// Streaming reader of the worksheet xml. It reads the cell types and styles,
+// the hidden rows and columns and the merged cells without loading the whole
+// worksheet in memory.
+type sheetScanner struct {
+	decoder     *xml.Decoder
+	closers     []io.Closer
+	row         int
+	pending     *xmlRow
+	eof         bool
+	hidden_cols map[int]bool
+	merges      []string
+}
+
+// Open the worksheet xml from the workbook package. Large worksheets are not
+// kept in memory by excelize, these are read from the excel file. Password
+// protected workbooks are decrypted again since the file is not a zip.
+func openSheetScanner(f *excelize.File, sheet string, args *ConfigurationWorkbook) (*sheetScanner, error) {
+	name, err := getSheetXMLPath(f, sheet)
+	if err != nil {
+		return nil, err
+	}
+	s := &sheetScanner{hidden_cols: make(map[int]bool)}
+	if content, ok := f.Pkg.Load(name); ok {
+		if b, ok := content.([]byte); ok && len(b) > 0 {
+			s.decoder = xml.NewDecoder(bytes.NewReader(b))
+			return s, nil
+		}
+	}
+	var files []*zip.File
+	if len(args.excel_data) > 0 || args.excel_pass != "" {
+		data := args.excel_data
+		if len(data) == 0 {
+			if data, err = os.ReadFile(args.excel_file); err != nil {
+				return nil, fmt.Errorf("unable to read worksheet \"%s\": %v", sheet, err)
+			}
+		}
+		if args.excel_pass != "" {
+			if data, err = excelize.Decrypt(data, &excelize.Options{Password: args.excel_pass}); err != nil {
+				return nil, fmt.Errorf("unable to read worksheet \"%s\": %v", sheet, err)
+			}
+		}
+		z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read worksheet \"%s\": %v", sheet, err)
+		}
+		files = z.File
+	} else {
+		z, err := zip.OpenReader(args.excel_file)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read worksheet \"%s\": %v", sheet, err)
+		}
+		s.closers = append(s.closers, z)
+		files = z.File
+	}
+	for _, file := range files {
+		if file.Name == name {
+			rc, err := file.Open()
+			if err != nil {
+				s.close()
+				return nil, err
+			}
+			s.closers = append(s.closers, rc)
+			s.decoder = xml.NewDecoder(rc)
+			return s, nil
+		}
+	}
+	s.close()
+	return nil, fmt.Errorf("unable to read worksheet \"%s\": %s not found", sheet, name)
+}
+
+func (s *sheetScanner) close() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i].Close()
+	}
+	s.closers = nil
+}
+
+// Get the row of the worksheet. Rows are requested in ascending order, an
+// empty row is returned for rows not in the worksheet xml.
+func (s *sheetScanner) getRow(num int) (*xmlRow, error) {
+	for !s.eof && (s.pending == nil || s.pending.num < num) {
+		row, err := s.readRow()
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			s.pending = row
+		}
+	}
+	if s.pending != nil && s.pending.num == num {
+		return s.pending, nil
+	}
+	return &xmlRow{num: num}, nil
+}
+
+// Read the rest of the worksheet xml and get the merged cell ranges
+func (s *sheetScanner) getMergeCells() ([]string, error) {
+	for !s.eof {
+		if _, err := s.readRow(); err != nil {
+			return nil, err
+		}
+	}
+	return s.merges, nil
+}
+
+// Read the worksheet xml up to the next row. The hidden columns and merged
+// cells are collected on the way. A nil row is returned at the end.
+func (s *sheetScanner) readRow() (*xmlRow, error) {
+	for {
+		token, err := s.decoder.Token()
+		if err == io.EOF {
+			s.eof = true
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "col":
+			if xmlBoolAttr(start, "hidden") {
+				min, _ := strconv.Atoi(xmlAttr(start, "min"))
+				max, _ := strconv.Atoi(xmlAttr(start, "max"))
+				for c := min; c <= max && c <= maxExcelColumns; c++ {
+					s.hidden_cols[c-1] = true
+				}
+			}
+		case "mergeCell":
+			s.merges = append(s.merges, xmlAttr(start, "ref"))
+		case "row":
+			s.row++
+			if num, err := strconv.Atoi(xmlAttr(start, "r")); err == nil {
+				s.row = num
+			}
+			row := &xmlRow{num: s.row, hidden: xmlBoolAttr(start, "hidden"), cells: make(map[int]xmlCell)}
+			col := 0
+			for {
+				token, err := s.decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				if element, ok := token.(xml.StartElement); ok && element.Name.Local == "c" {
+					var cell xmlCell
+					if err := s.decoder.DecodeElement(&cell, &element); err != nil {
+						return nil, err
+					}
+					col++
+					if cell.R != "" {
+						if col, _, err = excelize.CellNameToCoordinates(cell.R); err != nil {
+							return nil, err
+						}
+					}
+					row.cells[col-1] = cell
+				}
+				if element, ok := token.(xml.EndElement); ok && element.Name.Local == "row" {
+					return row, nil
+				}
+			}
+		}
+	}
+}
+
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func xmlBoolAttr(element xml.StartElement, name string) bool {
+	value := xmlAttr(element, name)
+	return value == "1" || value == "true"
+}
+
+// Get the path of the worksheet xml in the workbook package
+func getSheetXMLPath(f *excelize.File, sheet string) (string, error) {
+	type relationships struct {
+		Relationship []struct {
+			ID     string `xml:"Id,attr"`
+			Type   string `xml:"Type,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+
+	workbook_path := "xl/workbook.xml"
+	var rels relationships
+	if err := readPackageXML(f, "_rels/.rels", &rels); err == nil {
+		for _, rel := range rels.Relationship {
+			if strings.HasSuffix(rel.Type, "/officeDocument") {
+				workbook_path = strings.TrimPrefix(rel.Target, "/")
+			}
+		}
+	}
+	if err := readPackageXML(f, workbook_path, &workbook); err != nil {
+		return "", err
+	}
+	rels = relationships{}
+	if err := readPackageXML(f, path.Join(path.Dir(workbook_path), "_rels", path.Base(workbook_path)+".rels"), &rels); err != nil {
+		return "", err
+	}
+	for _, ws := range workbook.Sheets {
+		if !strings.EqualFold(ws.Name, sheet) {
+			continue
+		}
+		for _, rel := range rels.Relationship {
+			if rel.ID == ws.ID {
+				if strings.HasPrefix(rel.Target, "/") {
+					return path.Clean(strings.TrimPrefix(rel.Target, "/")), nil
+				}
+				return path.Join(path.Dir(workbook_path), rel.Target), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("worksheet \"%s\" not found in workbook", sheet)
+}
+
+func readPackageXML(f *excelize.File, name string, v interface{}) error {
+	content, ok := f.Pkg.Load(name)
+	if !ok {
+		return fmt.Errorf("%s not found in workbook", name)
+	}
+	b, ok := content.([]byte)
+	if !ok {
+		return fmt.Errorf("%s not found in workbook", name)
+	}
+	return xml.Unmarshal(b, v)
+}
+
+// Style filters of the data source. The names are the texts of the header row
+// for horizontal orientation or of the first column for vertical orientation.
+type styleFilter struct {
+	f      *excelize.File
+	args   *ConfigurationWorkbook
+	styles map[int]*excelize.Style
+	// position of the filter columns in the names
+	index map[string]int
+}
+
+func newStyleFilter(f *excelize.File, names []string, args *ConfigurationWorkbook) (*styleFilter, error) {
+	s := &styleFilter{f: f, args: args, styles: make(map[int]*excelize.Style), index: make(map[string]int)}
+	for _, sf := range args.style_filters {
+		column := sf["Column"].(string)
+		s.index[column] = -1
+		for i, name := range names {
+			if name == column {
+				s.index[column] = i
+				break
+			}
+		}
+		if s.index[column] < 0 {
+			return nil, fmt.Errorf("style_filter column \"%s\" not found in %s", column, describeWorkbookSource(args))
+		}
+	}
+	return s, nil
+}
+
+func (s *styleFilter) getStyle(cell excelCell) *excelize.Style {
+	if _, ok := s.styles[cell.style]; !ok {
+		style, err := s.f.GetStyle(cell.style)
+		if err != nil {
+			style = &excelize.Style{}
+		}
+		s.styles[cell.style] = style
+	}
+	return s.styles[cell.style]
+}
+
+// Check if the record is included by the style filters, get returns the cell
+// at the position of a filter column
+func (s *styleFilter) include(get func(idx int) excelCell) bool {
+	has_include, included := false, false
+	for _, sf := range s.args.style_filters {
+		match := styleMatches(s.getStyle(get(s.index[sf["Column"].(string)])), sf)
+		if sf["Action"] == "exclude" && match {
+			return false
+		}
+		if sf["Action"] == "include" {
+			has_include = true
+			included = included || match
+		}
+	}
+	return !has_include || included
+}
+
+// Check if the row is included by the style filters (horizontal orientation)
+func (s *styleFilter) includeRow(row []excelCell) bool {
+	return s.include(func(idx int) excelCell {
+		if idx < len(row) {
+			return row[idx]
+		}
+		return excelCell{}
+	})
+}
+
+// Apply the style filters on the cell of the filter column. Rows not matching
+// are deleted for horizontal orientation. For vertical orientation, the
+// columns not matching are returned.
+func applyStyleFilters(f *excelize.File, rows [][]excelCell, args *ConfigurationWorkbook) ([][]excelCell, map[int]bool, error) {
+	excluded := make(map[int]bool)
+	if args.orientation == "horizontal" {
+		filter, err := newStyleFilter(f, cellTexts(rows[0]), args)
+		if err != nil {
+			return nil, nil, err
+		}
+		result := [][]excelCell{rows[0]}
+		for _, row := range rows[1:] {
+			if filter.includeRow(row) {
+				result = append(result, row)
+			}
+		}
+		return result, excluded, nil
+	}
+
+	names := make([]string, len(rows))
+	for i, row := range rows {
+		if len(row) > 0 {
+			names[i] = row[0].text
+		}
+	}
+	filter, err := newStyleFilter(f, names, args)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxcol := 0
+	for _, row := range rows {
+		if len(row) > maxcol {
+			maxcol = len(row)
+		}
+	}
+	for i := 1; i < maxcol; i++ {
+		if !filter.include(func(idx int) excelCell {
+			if i < len(rows[idx]) {
+				return rows[idx][i]
+			}
+			return excelCell{}
+		}) {
+			excluded[i] = true
+		}
+	}
+	return rows, excluded, nil
+}
+
+// Check if the cell style has the strikethrough, fill color and font color of the style filter
+func styleMatches(style *excelize.Style, sf map[string]interface{}) bool {
+	if sf["Strikethrough"].(bool) && (style.Font == nil || !style.Font.Strike) {
+		return false
+	}
+	if color := sf["FillColor"].(string); color != "" {
+		if len(style.Fill.Color) == 0 || normalizeColor(style.Fill.Color[0]) != color {
+			return false
+		}
+	}
+	if color := sf["FontColor"].(string); color != "" {
+		if style.Font == nil || normalizeColor(style.Font.Color) != color {
+			return false
+		}
+	}
+	return true
+}
+
+// Delete the rows of the data flagged as hidden. The header row is kept.
+func deleteHiddenRows(rows [][]excelCell, keep_header bool) [][]excelCell {
+	var r [][]excelCell
+	for idx, row := range rows {
+		hidden := false
+		for _, cell := range row {
+			if cell.hidden_row {
+				hidden = true
+				break
+			}
+		}
+		if !hidden || (keep_header && idx == 0) {
+			r = append(r, row)
+		}
+	}
+	return r
+}
+
+// Get the columns of the data flagged as hidden
+func getHiddenColumns(rows [][]excelCell) map[int]bool {
+	hidden := make(map[int]bool)
+	for _, row := range rows {
+		for c, cell := range row {
+			if cell.hidden_col {
+				hidden[c] = true
+			}
+		}
+	}
+	return hidden
+}
+
+// Copy the value of a merged cell to every cell of the merged range if
+// merged_cells is fill. Otherwise, the cells are flagged with the merged range
+// so that merged cells in the data can be reported.
+func applyMergedCells(sheet string, rows [][]excelCell, merges []string, args *ConfigurationWorkbook) ([][]excelCell, error) {
+	for _, m := range merges {
+		area, err := parseAreaReference(m)
+		if err != nil {
+			return nil, fmt.Errorf("worksheet \"%s\": %v", sheet, err)
+		}
+		first := excelCell{}
+		if area.start_row < len(rows) && area.start_col < len(rows[area.start_row]) {
+			first = rows[area.start_row][area.start_col]
+		}
+		for len(rows) <= area.end_row {
+			rows = append(rows, []excelCell{})
+		}
+		for r := area.start_row; r <= area.end_row; r++ {
+			for len(rows[r]) <= area.end_col {
+				rows[r] = append(rows[r], excelCell{row: r + 1, col: len(rows[r]) + 1})
+			}
+			for c := area.start_col; c <= area.end_col; c++ {
+				if args.merged_cells == "fill" {
+					rows[r][c].text = first.text
+					rows[r][c].value = first.value
+				} else {
+					rows[r][c].merged = m
+				}
+			}
+		}
+	}
+	return rows, nil
+}
+
+// Replace the value of formula cells. The used range of the worksheet is
+// scanned since the cached value of a formula cell can be missing.
+func applyFormulas(f *excelize.File, sheet string, rows [][]excelCell, date1904 bool, dateStyles map[int]bool, args *ConfigurationWorkbook) ([][]excelCell, error) {
+	max_row := len(rows)
+	max_col := 0
+	for _, row := range rows {
+		if len(row) > max_col {
+			max_col = len(row)
+		}
+	}
+	if dimension, err := f.GetSheetDimension(sheet); err == nil && dimension != "" {
+		if area, err := parseAreaReference(dimension); err == nil {
+			if area.end_row+1 > max_row {
+				max_row = area.end_row + 1
+			}
+			if area.end_col+1 > max_col {
+				max_col = area.end_col + 1
+			}
+		}
+	}
+
+	for r := 0; r < max_row; r++ {
+		for c := 0; c < max_col; c++ {
+			cell, err := excelize.CoordinatesToCellName(c+1, r+1)
+			if err != nil {
+				return nil, err
+			}
+			formula, err := f.GetCellFormula(sheet, cell)
+			if err != nil {
+				return nil, err
+			}
+			if formula == "" {
+				continue
+			}
+			for len(rows) <= r {
+				rows = append(rows, []excelCell{})
+			}
+			for len(rows[r]) <= c {
+				rows[r] = append(rows[r], excelCell{row: r + 1, col: len(rows[r]) + 1})
+			}
+			// the style, layout and merge of the cell are kept
+			value := &rows[r][c]
+			value.text = "=" + formula
+			value.value = nil
+			if args.formulas == "calculate" {
+				// the error value (#N/A, #REF!, ...) is returned as result on failure
+				value.text, err = f.CalcCellValue(sheet, cell, excelize.Options{RawCellValue: args.value_format == "raw"})
+				if err != nil && !isFormulaError(value.text) {
+					return nil, fmt.Errorf("worksheet \"%s\" cell %s: unable to calculate formula \"%s\": %v", sheet, cell, formula, err)
+				}
+				if args.native_types {
+					value.value = value.text
+					if raw, err := f.CalcCellValue(sheet, cell, excelize.Options{RawCellValue: true}); err == nil {
+						// numbers with a date format are dates like the other cells
+						value.value = nativeValue(f, xmlCell{S: value.style, V: raw}, value.text, date1904, dateStyles)
+					}
+				}
+			} else if args.native_types {
+				value.value = value.text
+			}
+		}
+	}
+	return rows, nil
+}
+
+// Get the style of a cell. GetStyle returns the last custom number format of
+// the workbook for all the styles, the format of the style is used instead.
+func getCellStyle(f *excelize.File, id int) (*excelize.Style, error) {
+	style, err := f.GetStyle(id)
+	if err != nil || style.CustomNumFmt == nil || f.Styles == nil || f.Styles.CellXfs == nil || id >= len(f.Styles.CellXfs.Xf) {
+		return style, err
+	}
+	style.CustomNumFmt = nil
+	if num_fmt := f.Styles.CellXfs.Xf[id].NumFmtID; num_fmt != nil && f.Styles.NumFmts != nil {
+		for _, n := range f.Styles.NumFmts.NumFmt {
+			if n.NumFmtID == *num_fmt {
+				code := n.FormatCode
+				style.CustomNumFmt = &code
+			}
+		}
+	}
+	return style, nil
+}
+
+// Check if the number format of the style is a date or time format
+func isDateNumFmt(style *excelize.Style) bool {
+	if style.CustomNumFmt != nil {
+		code := *style.CustomNumFmt
+		// ignore literal text, colors and locales like "day" or [$-409]
+		var sb strings.Builder
+		quoted, bracket, escaped := false, false, false
+		for _, r := range code {
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == '"':
+				quoted = !quoted
+			case quoted:
+			case r == '[':
+				bracket = true
+			case r == ']':
+				bracket = false
+			case bracket:
+			default:
+				sb.WriteRune(r)
+			}
+		}
+		return strings.ContainsAny(strings.ToLower(sb.String()), "ymdhs")
+	}
+	// built-in date and time formats
+	id := style.NumFmt
+	return (id >= 14 && id <= 22) || (id >= 27 && id <= 36) || (id >= 45 && id <= 47) || (id >= 50 && id <= 58) || (id >= 71 && id <= 81)
+}
+
+// Excel error values of formula cells
+var formulaErrors = []string{"#NULL!", "#DIV/0!", "#VALUE!", "#REF!", "#NAME?", "#NUM!", "#N/A", "#GETTING_DATA", "#SPILL!", "#CALC!"}
+
+func isFormulaError(value string) bool {
+	return stringInList(strings.TrimSpace(value), formulaErrors)
+}
+
+// Add the cells of the row having an error value (ex. D5 #DIV/0!)
+func appendFormulaErrors(cells []string, row []excelCell) []string {
+	for _, value := range row {
+		if isFormulaError(value.text) {
+			cell, _ := excelize.CoordinatesToCellName(value.col, value.row)
+			cells = append(cells, cell+" "+strings.TrimSpace(value.text))
+		}
+	}
+	return cells
+}
+
+// Report the cells having an error value as a warning
+func reportFormulaErrors(sheet string, cells []string, args *ConfigurationWorkbook) {
+	if len(cells) > 0 {
+		addWarning(args, fmt.Sprintf("worksheet \"%s\" has %d cells with error values", sheet, len(cells)), strings.Join(cells, ", "))
+	}
+}
+
+// Keep only the header row and the data rows of the worksheet. Row numbers are
+// the worksheet row numbers (1-based).
+func applyRowWindow(rows [][]excelCell, args *ConfigurationWorkbook) ([][]excelCell, error) {
+	if args.header_row == 0 && args.first_data_row == 0 && args.last_data_row == 0 && !args.detect_header {
+		return rows, nil
+	}
+
+	header := 0
+	if args.header_row > 0 {
+		header = args.header_row - 1
+	} else if args.detect_header {
+		header = -1
+		for idx, row := range rows {
+			for _, cell := range row {
+				if strings.TrimSpace(cell.text) == args.col_config_item {
+					header = idx
+					break
+				}
+			}
+			if header >= 0 {
+				break
+			}
+		}
+		if header < 0 {
+			return nil, fmt.Errorf("header row with column \"%s\" not found in worksheet \"%s\"", args.col_config_item, args.sheet_name)
+		}
+	} else {
+		// without a header row, the header is the first non empty row
+		for header < len(rows) && !is_printable(cellTexts(rows[header])) {
+			header++
+		}
+	}
+	if header >= len(rows) {
+		return nil, fmt.Errorf("header row %d is beyond the last row of worksheet \"%s\"", header+1, args.sheet_name)
+	}
+
+	first := header + 1
+	if args.first_data_row > 0 {
+		first = args.first_data_row - 1
+	}
+	if first <= header {
+		return nil, fmt.Errorf("first_data_row must be after the header row %d", header+1)
+	}
+	last := len(rows) - 1
+	if args.last_data_row > 0 && args.last_data_row-1 < last {
+		last = args.last_data_row - 1
+	}
+
+	result := [][]excelCell{rows[header]}
+	for r := first; r <= last; r++ {
+		result = append(result, rows[r])
+	}
+	return result, nil
+}
+
+// Get the worksheets matching the names or glob patterns (ex. vm_*) in
+// workbook order. A name without a pattern must exist in the workbook. Hidden
+// worksheets are skipped by patterns unless include_hidden is set.
+func matchWorksheets(f *excelize.File, patterns []string, include_hidden bool) ([]string, error) {
+	var sheets []string
+	for _, pattern := range patterns {
+		matched := false
+		for _, sheet := range f.GetSheetList() {
+			ok, err := path.Match(pattern, sheet)
+			if err != nil {
+				return nil, fmt.Errorf("invalid worksheet pattern \"%s\": %v", pattern, err)
+			}
+			// hidden worksheets only match by name
+			if ok && !include_hidden && pattern != sheet {
+				visible, err := f.GetSheetVisible(sheet)
+				if err != nil {
+					return nil, err
+				}
+				ok = visible
+			}
+			if ok {
+				matched = true
+				if !stringInList(sheet, sheets) {
+					sheets = append(sheets, sheet)
+				}
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no worksheet matches \"%s\"", pattern)
+		}
+	}
+	return sheets, nil
+}
+
+func checkSheetExists(f *excelize.File, sheet string) error {
+	sheetId, err := f.GetSheetIndex(sheet)
+	if err != nil {
+		return err
+	}
+	if sheetId < 0 {
+		return fmt.Errorf("worksheet \"%s\" not found", sheet)
+	}
+	return nil
+}
+
+// Find the excel table (ListObject) by name. Table names are case insensitive in excel
+func getTableArea(f *excelize.File, name string, sheet string) (excelArea, error) {
+	sheets := f.GetSheetList()
+	if sheet != "" {
+		if err := checkSheetExists(f, sheet); err != nil {
+			return excelArea{}, err
+		}
+		sheets = []string{sheet}
+	}
+	for _, s := range sheets {
+		tables, err := f.GetTables(s)
+		if err != nil {
+			return excelArea{}, err
+		}
+		for _, t := range tables {
+			if !strings.EqualFold(t.Name, name) {
+				continue
+			}
+			if t.ShowHeaderRow != nil && !*t.ShowHeaderRow {
+				return excelArea{}, fmt.Errorf("table \"%s\" does not have a header row", name)
+			}
+			area, err := parseAreaReference(t.Range)
+			if err != nil {
+				return excelArea{}, fmt.Errorf("table \"%s\": %v", name, err)
+			}
+			area.sheet = s
+			return area, nil
+		}
+	}
+	return excelArea{}, fmt.Errorf("table \"%s\" not found", name)
+}
+
+// Find the defined name and resolve all the areas it refers to. A name scoped
+// to the worksheet takes precedence over a workbook scoped name.
+func getDefinedNameAreas(f *excelize.File, name string, sheet string) ([]excelArea, error) {
+	refersTo := ""
+	found := false
+	for _, dn := range f.GetDefinedName() {
+		if !strings.EqualFold(dn.Name, name) {
+			continue
+		}
+		if sheet != "" && dn.Scope == sheet {
+			refersTo = dn.RefersTo
+			found = true
+			break
+		}
+		if dn.Scope == "Workbook" || dn.Scope == "" {
+			refersTo = dn.RefersTo
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("defined name \"%s\" not found", name)
+	}
+
+	var areas []excelArea
+	for _, ref := range splitReferences(strings.TrimPrefix(strings.TrimSpace(refersTo), "=")) {
+		ref = strings.TrimSpace(ref)
+		idx := strings.LastIndex(ref, "!")
+		if idx < 0 {
+			return nil, fmt.Errorf("defined name \"%s\" refers to \"%s\" which is not a cell range", name, refersTo)
+		}
+		area, err := parseAreaReference(ref[idx+1:])
+		if err != nil {
+			return nil, fmt.Errorf("defined name \"%s\": %v", name, err)
+		}
+		sheets, err := expandSheetReference(f, ref[:idx])
+		if err != nil {
+			return nil, fmt.Errorf("defined name \"%s\": %v", name, err)
+		}
+		for _, s := range sheets {
+			a := area
+			a.sheet = s
+			areas = append(areas, a)
+		}
+	}
+	return areas, nil
+}
+
+// Split a reference list (Sheet1!A1:B2,'Sheet, 2'!A1:B2) on commas outside of quoted sheet names
+func splitReferences(s string) []string {
+	var refs []string
+	var sb strings.Builder
+	quoted := false
+	for _, r := range s {
+		if r == '\'' {
+			quoted = !quoted
+		}
+		if r == ',' && !quoted {
+			refs = append(refs, sb.String())
+			sb.Reset()
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() > 0 {
+		refs = append(refs, sb.String())
+	}
+	return refs
+}
+
+// Expand a sheet reference into sheet names. A 3D reference (Sheet1:Sheet3)
+// includes every sheet between the two sheets in workbook order.
+func expandSheetReference(f *excelize.File, ref string) ([]string, error) {
+	ref = strings.TrimSpace(ref)
+	if strings.HasPrefix(ref, "'") && strings.HasSuffix(ref, "'") && len(ref) > 1 {
+		ref = strings.ReplaceAll(ref[1:len(ref)-1], "''", "'")
+	}
+	names := strings.SplitN(ref, ":", 2)
+	for _, n := range names {
+		if err := checkSheetExists(f, n); err != nil {
+			return nil, err
+		}
+	}
+	if len(names) == 1 {
+		return names, nil
+	}
+	var sheets []string
+	in := false
+	for _, s := range f.GetSheetList() {
+		if s == names[0] || s == names[1] {
+			sheets = append(sheets, s)
+			if in || names[0] == names[1] {
+				break
+			}
+			in = true
+			continue
+		}
+		if in {
+			sheets = append(sheets, s)
+		}
+	}
+	return sheets, nil
+}
+
+// Parse a range reference like $A$1:$D$10, A1, or A:D
+func parseAreaReference(ref string) (excelArea, error) {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(ref), "$", ""), ":")
+	if len(parts) > 2 || parts[0] == "" {
+		return excelArea{}, fmt.Errorf("invalid range \"%s\"", ref)
+	}
+	if len(parts) == 1 {
+		parts = append(parts, parts[0])
+	}
+	start_col, start_row, err := parseCellReference(parts[0])
+	if err != nil {
+		return excelArea{}, fmt.Errorf("invalid range \"%s\": %v", ref, err)
+	}
+	end_col, end_row, err := parseCellReference(parts[1])
+	if err != nil {
+		return excelArea{}, fmt.Errorf("invalid range \"%s\": %v", ref, err)
+	}
+	if (start_row < 0) != (end_row < 0) || start_col > end_col || end_row < start_row {
+		return excelArea{}, fmt.Errorf("invalid range \"%s\"", ref)
+	}
+	if start_row < 0 {
+		start_row = 0
+	}
+	return excelArea{start_col: start_col, end_col: end_col, start_row: start_row, end_row: end_row}, nil
+}
+
+// Parse a cell reference into zero-based column and row indexes. The row is -1
+// if the reference is a whole column.
+func parseCellReference(ref string) (int, int, error) {
+	i := strings.IndexAny(ref, "0123456789")
+	if i < 0 {
+		col, err := columnNameToIndex(ref)
+		return col, -1, err
+	}
+	col, err := columnNameToIndex(ref[:i])
+	if err != nil {
+		return -1, -1, err
+	}
+	row := 0
+	for _, r := range ref[i:] {
+		if r < '0' || r > '9' {
+			return -1, -1, fmt.Errorf("\"%s\" is not a valid cell reference", ref)
+		}
+		row = row*10 + int(r-'0')
+		if row > excelize.TotalRows {
+			return -1, -1, fmt.Errorf("row of \"%s\" is beyond the last excel row", ref)
+		}
+	}
+	if row == 0 {
+		return -1, -1, fmt.Errorf("\"%s\" is not a valid cell reference", ref)
+	}
+	return col, row - 1, nil
+}
+
+// Get the cells of the areas. The first row of the first area is the header,
+// the header row of the following areas is dropped if repeated.
+func getAreaRows(f *excelize.File, areas []excelArea, args *ConfigurationWorkbook) ([][]excelCell, error) {
+	var result [][]excelCell
+	sheetRows := make(map[string][][]excelCell)
+	for idx, area := range areas {
+		rows, ok := sheetRows[area.sheet]
+		if !ok {
+			var err error
+			// read the worksheet up to the last row of its areas
+			last_row := 0
+			for _, a := range areas {
+				if a.sheet == area.sheet {
+					if a.end_row < 0 {
+						last_row = 0
+						break
+					}
+					if a.end_row+1 > last_row {
+						last_row = a.end_row + 1
+					}
+				}
+			}
+			rows, err = getSheetRows(f, area.sheet, last_row, args)
+			if err != nil {
+				return nil, err
+			}
+			sheetRows[area.sheet] = rows
+		}
+		end_row := area.end_row
+		if end_row < 0 || end_row > len(rows)-1 {
+			end_row = len(rows) - 1
+		}
+		for r := area.start_row; r <= end_row; r++ {
+			cells := getAreaCells(rows[r], r, area)
+			if idx > 0 && r == area.start_row && len(result) > 0 && strings.Join(cellTexts(cells), "\x00") == strings.Join(cellTexts(result[0]), "\x00") {
+				continue
+			}
+			result = append(result, cells)
+		}
+	}
+	return result, nil
+}
+
+// Get the cells of a worksheet row (zero-based) in the columns of the area
+func getAreaCells(row []excelCell, r int, area excelArea) []excelCell {
+	cells := []excelCell{}
+	for c := area.start_col; c <= area.end_col; c++ {
+		if c < len(row) {
+			cells = append(cells, row[c])
+		} else {
+			cells = append(cells, excelCell{row: r + 1, col: c + 1})
+		}
+	}
+	return cells
+}
+
+// Describe the source of the rows for error messages
+func describeWorkbookSource(args *ConfigurationWorkbook) string {
+	if args.table != "" {
+		return fmt.Sprintf("table \"%s\"", args.table)
+	}
+	if args.defined_name != "" {
+		return fmt.Sprintf("defined name \"%s\"", args.defined_name)
+	}
+	return fmt.Sprintf("worksheet \"%s\"", args.sheet_name)
+}
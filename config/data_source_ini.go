@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -16,7 +17,15 @@ func dataSourceIni() *schema.Resource {
 		Schema: map[string]*schema.Schema{
 			"ini": {
 				Type:     schema.TypeString,
-				Required: true,
+				Optional: true,
+			},
+			"archive": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"member": {
+				Type:     schema.TypeString,
+				Optional: true,
 			},
 			"section": {
 				Type:     schema.TypeString,
@@ -35,9 +44,29 @@ func dataSourceIniRead(ctx context.Context, d *schema.ResourceData, m interface{
 	var diags diag.Diagnostics
 
 	section := d.Get("section").(string)
+	content := d.Get("ini").(string)
+	archive := d.Get("archive").(string)
+	member := d.Get("member").(string)
+
+	// the ini file can be extracted from a zip or tar.gz archive
+	if archive != "" || member != "" {
+		if archive == "" || member == "" {
+			return diag.FromErr(fmt.Errorf("archive and member must be used together"))
+		}
+		if content != "" {
+			return diag.FromErr(fmt.Errorf("Cannot use ini and archive on the same resource"))
+		}
+		_, data, err := readArchiveMember(archive, member)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		content = string(data)
+	} else if content == "" {
+		return diag.FromErr(fmt.Errorf("Must use ini or archive on the resource"))
+	}
 
 	// var ini map[string]map[string]interface{}
-	ini := iniParser(d.Get("ini"))
+	ini := iniParser(content)
 	if section != "" {
 		data, _ := json.Marshal(ini[section])
 		if e := d.Set("json", string(data)); e != nil {
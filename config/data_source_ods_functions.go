@@ -0,0 +1,911 @@
+package config
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Namespaces of the OpenDocument xml
+const (
+	odsOfficeNS   = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsStyleNS    = "urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+	odsTableNS    = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsTextNS     = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odsNumberNS   = "urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0"
+	odsManifestNS = "urn:oasis:names:tc:opendocument:xmlns:manifest:1.0"
+)
+
+const odsMimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+// Convert an OpenDocument spreadsheet (.ods) to an excel workbook in memory.
+// The spreadsheet is detected by content, nil is returned for other files.
+func convertODS(excel_file string, excel_data []byte) ([]byte, error) {
+	var files []*zip.File
+	if len(excel_data) > 0 {
+		z, err := zip.NewReader(bytes.NewReader(excel_data), int64(len(excel_data)))
+		if err != nil {
+			return nil, nil
+		}
+		files = z.File
+	} else {
+		z, err := zip.OpenReader(excel_file)
+		if err != nil {
+			return nil, nil
+		}
+		defer z.Close()
+		files = z.File
+	}
+	if !isODSPackage(files) {
+		return nil, nil
+	}
+
+	r := &odsReader{
+		f:           excelize.NewFile(),
+		files:       make(map[string]*zip.File),
+		cell_styles: make(map[string]*odsStyle),
+		hidden:      make(map[string]bool),
+		data_styles: make(map[string]*odsDataStyle),
+		style_ids:   make(map[string]int),
+	}
+	defer r.f.Close()
+	for _, file := range files {
+		r.files[file.Name] = file
+	}
+	if err := r.checkEncryption(); err != nil {
+		return nil, err
+	}
+	if err := r.readXML("styles.xml"); err != nil {
+		return nil, err
+	}
+	if err := r.readXML("content.xml"); err != nil {
+		return nil, err
+	}
+	if len(r.sheets) == 0 {
+		return nil, fmt.Errorf("ods workbook has no sheets")
+	}
+
+	// the active sheet must be visible to hide the other sheets
+	for i, sheet := range r.sheets {
+		if !r.sheet_hidden[sheet] {
+			r.f.SetActiveSheet(i)
+			break
+		}
+	}
+	for _, sheet := range r.sheets {
+		if r.sheet_hidden[sheet] {
+			if err := r.f.SetSheetVisible(sheet, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	buf, err := r.f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Check the mimetype of the zip package
+func isODSPackage(files []*zip.File) bool {
+	for _, file := range files {
+		if file.Name != "mimetype" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return false
+		}
+		defer rc.Close()
+		b, _ := io.ReadAll(io.LimitReader(rc, 256))
+		return strings.HasPrefix(strings.TrimSpace(string(b)), odsMimeType)
+	}
+	return false
+}
+
+// Style of the cells (family table-cell) or of the sheets (family table)
+type odsStyle struct {
+	Name      string `xml:"urn:oasis:names:tc:opendocument:xmlns:style:1.0 name,attr"`
+	Family    string `xml:"urn:oasis:names:tc:opendocument:xmlns:style:1.0 family,attr"`
+	Parent    string `xml:"urn:oasis:names:tc:opendocument:xmlns:style:1.0 parent-style-name,attr"`
+	DataStyle string `xml:"urn:oasis:names:tc:opendocument:xmlns:style:1.0 data-style-name,attr"`
+	Text      *struct {
+		LineThrough string `xml:"urn:oasis:names:tc:opendocument:xmlns:style:1.0 text-line-through-style,attr"`
+		Color       string `xml:"urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0 color,attr"`
+	} `xml:"urn:oasis:names:tc:opendocument:xmlns:style:1.0 text-properties"`
+	Cell *struct {
+		Background string `xml:"urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0 background-color,attr"`
+	} `xml:"urn:oasis:names:tc:opendocument:xmlns:style:1.0 table-cell-properties"`
+	Table *struct {
+		Display string `xml:"urn:oasis:names:tc:opendocument:xmlns:table:1.0 display,attr"`
+	} `xml:"urn:oasis:names:tc:opendocument:xmlns:style:1.0 table-properties"`
+}
+
+// Number format of a data style. The maps apply another data style to the
+// values matching the condition (ex. the positive values).
+type odsDataStyle struct {
+	format string
+	maps   [][2]string
+}
+
+// A cell of the spreadsheet. The text is the displayed value of the cell.
+type odsCell struct {
+	repeat     int
+	col_span   int
+	row_span   int
+	style      string
+	value_type string
+	value      string
+	formula    string
+	text       string
+}
+
+// A column of the spreadsheet
+type odsColumn struct {
+	end    int
+	style  string
+	hidden bool
+}
+
+// Converter of the spreadsheet xml to the excel workbook
+type odsReader struct {
+	f            *excelize.File
+	files        map[string]*zip.File
+	cell_styles  map[string]*odsStyle
+	hidden       map[string]bool
+	data_styles  map[string]*odsDataStyle
+	style_ids    map[string]int
+	sheets       []string
+	sheet_hidden map[string]bool
+	sheet        string
+	columns      []odsColumn
+	row          int
+}
+
+// Encrypted spreadsheets have the encryption data in the manifest
+func (r *odsReader) checkEncryption() error {
+	file, ok := r.files["META-INF/manifest.xml"]
+	if !ok {
+		return nil
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	d := xml.NewDecoder(rc)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read the manifest of the ods workbook: %v", err)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Space == odsManifestNS && start.Name.Local == "encryption-data" {
+			return fmt.Errorf("password protected ods workbooks are not supported")
+		}
+	}
+}
+
+// Read the styles and the sheets of the xml file of the spreadsheet
+func (r *odsReader) readXML(name string) error {
+	file, ok := r.files[name]
+	if !ok {
+		if name == "content.xml" {
+			return fmt.Errorf("ods workbook has no content.xml")
+		}
+		return nil
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	d := xml.NewDecoder(rc)
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			switch t := token.(type) {
+			case xml.StartElement:
+				err = r.readElement(d, t)
+			case xml.EndElement:
+				if t.Name.Space == odsTableNS && t.Name.Local == "table" {
+					err = r.endSheet()
+				}
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read %s of the ods workbook: %v", name, err)
+		}
+	}
+}
+
+func (r *odsReader) readElement(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Space {
+	case odsStyleNS:
+		if start.Name.Local != "style" {
+			return nil
+		}
+		style := new(odsStyle)
+		if err := d.DecodeElement(style, &start); err != nil {
+			return err
+		}
+		switch style.Family {
+		case "table-cell":
+			r.cell_styles[style.Name] = style
+		case "table":
+			r.hidden[style.Name] = style.Table != nil && style.Table.Display == "false"
+		}
+	case odsNumberNS:
+		if strings.HasSuffix(start.Name.Local, "-style") {
+			return r.readDataStyle(d, start)
+		}
+	case odsTableNS:
+		switch start.Name.Local {
+		case "table":
+			return r.addSheet(odsAttr(start, odsTableNS, "name"), odsAttr(start, odsTableNS, "style-name"))
+		case "table-column":
+			repeat := odsRepeat(start, "number-columns-repeated")
+			start_col := 0
+			if len(r.columns) > 0 {
+				start_col = r.columns[len(r.columns)-1].end
+			}
+			visibility := odsAttr(start, odsTableNS, "visibility")
+			r.columns = append(r.columns, odsColumn{
+				end:    start_col + repeat,
+				style:  odsAttr(start, odsTableNS, "default-cell-style-name"),
+				hidden: visibility != "" && visibility != "visible",
+			})
+		case "table-row":
+			return r.readRow(d, start)
+		case "named-range":
+			return r.addDefinedName(odsAttr(start, odsTableNS, "name"), odsAttr(start, odsTableNS, "cell-range-address"))
+		}
+	}
+	return nil
+}
+
+// Add a sheet to the workbook. The first sheet replaces the default sheet of
+// the new workbook.
+func (r *odsReader) addSheet(name string, style string) error {
+	var err error
+	if len(r.sheets) == 0 {
+		err = r.f.SetSheetName("Sheet1", name)
+	} else {
+		_, err = r.f.NewSheet(name)
+	}
+	if err != nil {
+		return fmt.Errorf("sheet \"%s\": %v", name, err)
+	}
+	if r.sheet_hidden == nil {
+		r.sheet_hidden = make(map[string]bool)
+	}
+	r.sheets = append(r.sheets, name)
+	r.sheet_hidden[name] = r.hidden[style]
+	r.sheet = name
+	r.columns = nil
+	r.row = 0
+	return nil
+}
+
+// Hide the collapsed columns at the end of the current sheet
+func (r *odsReader) endSheet() error {
+	start_col := 0
+	for _, column := range r.columns {
+		if column.hidden && start_col < maxExcelColumns {
+			end_col := column.end
+			if end_col > maxExcelColumns {
+				end_col = maxExcelColumns
+			}
+			first, _ := excelize.ColumnNumberToName(start_col + 1)
+			last, _ := excelize.ColumnNumberToName(end_col)
+			if err := r.f.SetColVisible(r.sheet, first+":"+last, false); err != nil {
+				return err
+			}
+		}
+		start_col = column.end
+	}
+	r.sheet = ""
+	r.columns = nil
+	return nil
+}
+
+// Get the default cell style of the column
+func (r *odsReader) columnStyle(col int) string {
+	for _, column := range r.columns {
+		if col < column.end {
+			return column.style
+		}
+	}
+	return ""
+}
+
+// Read a row of the current sheet and write its cells to the workbook. Empty
+// rows are skipped, these are often repeated up to the last row.
+func (r *odsReader) readRow(d *xml.Decoder, start xml.StartElement) error {
+	repeat := odsRepeat(start, "number-rows-repeated")
+	visibility := odsAttr(start, odsTableNS, "visibility")
+	var cells []odsCell
+	last_col := 0
+	col := 0
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		if element, ok := token.(xml.StartElement); ok && element.Name.Space == odsTableNS {
+			if element.Name.Local == "table-cell" || element.Name.Local == "covered-table-cell" {
+				cell, err := readODSCell(d, element)
+				if err != nil {
+					return err
+				}
+				cells = append(cells, cell)
+				col += cell.repeat
+				if cell.text != "" || cell.value_type != "" || cell.formula != "" {
+					last_col = col
+				}
+			}
+		}
+		if element, ok := token.(xml.EndElement); ok && element.Name == start.Name {
+			break
+		}
+	}
+	if r.sheet == "" || last_col == 0 {
+		r.row += repeat
+		return nil
+	}
+
+	if last_col > maxExcelColumns {
+		last_col = maxExcelColumns
+	}
+	for i := 0; i < repeat && r.row < excelize.TotalRows; i++ {
+		r.row++
+		col := 0
+		for _, cell := range cells {
+			for c := 0; c < cell.repeat && col < last_col; c++ {
+				col++
+				if err := r.setCell(col, r.row, cell); err != nil {
+					return err
+				}
+			}
+		}
+		if visibility != "" && visibility != "visible" {
+			if err := r.f.SetRowVisible(r.sheet, r.row, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Write the value, formula, style and merged range of a cell
+func (r *odsReader) setCell(col int, row int, cell odsCell) error {
+	name, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil {
+		return err
+	}
+	kind := cell.value_type
+	switch cell.value_type {
+	case "float", "percentage", "currency":
+		n, err := strconv.ParseFloat(cell.value, 64)
+		if err != nil {
+			err = r.f.SetCellStr(r.sheet, name, cell.text)
+		} else {
+			err = r.f.SetCellFloat(r.sheet, name, n, -1, 64)
+		}
+		if err != nil {
+			return err
+		}
+	case "date":
+		var t time.Time
+		for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02"} {
+			if t, err = time.Parse(layout, cell.value); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			err = r.f.SetCellStr(r.sheet, name, cell.text)
+		} else {
+			if strings.Contains(cell.value, "T") {
+				kind = "datetime"
+			}
+			err = r.f.SetCellValue(r.sheet, name, t)
+		}
+		if err != nil {
+			return err
+		}
+	case "time":
+		days, ok := parseODSDuration(cell.value)
+		if ok {
+			err = r.f.SetCellFloat(r.sheet, name, days, -1, 64)
+		} else {
+			err = r.f.SetCellStr(r.sheet, name, cell.text)
+		}
+		if err != nil {
+			return err
+		}
+	case "boolean":
+		if err := r.f.SetCellBool(r.sheet, name, cell.value == "true"); err != nil {
+			return err
+		}
+	default:
+		if cell.text != "" {
+			if err := r.f.SetCellStr(r.sheet, name, cell.text); err != nil {
+				return err
+			}
+		}
+	}
+	if cell.formula != "" {
+		if err := r.f.SetCellFormula(r.sheet, name, odsFormula(cell.formula)); err != nil {
+			return err
+		}
+	}
+
+	style := cell.style
+	if style == "" {
+		style = r.columnStyle(col - 1)
+	}
+	if style == "" {
+		style = "Default"
+	}
+	id, err := r.styleID(style, kind)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		if err := r.f.SetCellStyle(r.sheet, name, name, id); err != nil {
+			return err
+		}
+	}
+
+	if cell.col_span > 1 || cell.row_span > 1 {
+		end, err := excelize.CoordinatesToCellName(col+odsMax(cell.col_span, 1)-1, row+odsMax(cell.row_span, 1)-1)
+		if err != nil {
+			return err
+		}
+		if err := r.f.MergeCell(r.sheet, name, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the excel style of the cell style. The properties of the parent styles
+// are used when not set on the style. The value type of the cell gives the
+// number format of cells without a data style.
+func (r *odsReader) styleID(name string, kind string) (int, error) {
+	key := name + "/" + kind
+	if id, ok := r.style_ids[key]; ok {
+		return id, nil
+	}
+	var data_style, line_through, color, background string
+	for depth := 0; name != "" && depth < 10; depth++ {
+		style, ok := r.cell_styles[name]
+		if !ok {
+			break
+		}
+		if data_style == "" {
+			data_style = style.DataStyle
+		}
+		if style.Text != nil {
+			if line_through == "" {
+				line_through = style.Text.LineThrough
+			}
+			if color == "" {
+				color = style.Text.Color
+			}
+		}
+		if style.Cell != nil && background == "" {
+			background = style.Cell.Background
+		}
+		name = style.Parent
+	}
+
+	style := &excelize.Style{}
+	format := r.numberFormat(data_style, 0)
+	if format == "" {
+		switch kind {
+		case "date":
+			format = "yyyy-mm-dd"
+		case "datetime":
+			format = "yyyy-mm-dd hh:mm:ss"
+		case "time":
+			format = "hh:mm:ss"
+		case "percentage":
+			format = "0.00%"
+		}
+	}
+	if format != "" {
+		style.CustomNumFmt = &format
+	}
+	if (line_through != "" && line_through != "none") || (color != "" && !strings.EqualFold(color, "#000000")) {
+		style.Font = &excelize.Font{Strike: line_through != "" && line_through != "none", Color: strings.TrimPrefix(color, "#")}
+	}
+	if background != "" && background != "transparent" {
+		style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{strings.TrimPrefix(background, "#")}}
+	}
+
+	id := 0
+	if style.CustomNumFmt != nil || style.Font != nil || style.Fill.Type != "" {
+		var err error
+		if id, err = r.f.NewStyle(style); err != nil {
+			return 0, err
+		}
+	}
+	r.style_ids[key] = id
+	return id, nil
+}
+
+// Get the excel number format of the data style. A data style applied to the
+// positive values is the first section of the number format.
+func (r *odsReader) numberFormat(name string, depth int) string {
+	data_style, ok := r.data_styles[name]
+	if !ok || depth > 5 {
+		return ""
+	}
+	for _, m := range data_style.maps {
+		if strings.ReplaceAll(m[0], " ", "") == "value()>=0" {
+			if positive := r.numberFormat(m[1], depth+1); positive != "" && data_style.format != "" {
+				return positive + ";" + data_style.format
+			}
+		}
+	}
+	return data_style.format
+}
+
+// Translate the elements of a data style (number:number-style,
+// number:date-style, ...) to an excel number format
+func (r *odsReader) readDataStyle(d *xml.Decoder, start xml.StartElement) error {
+	data_style := new(odsDataStyle)
+	var format strings.Builder
+	var text strings.Builder
+	in_text := false
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space == odsStyleNS && t.Name.Local == "map" {
+				data_style.maps = append(data_style.maps, [2]string{odsAttr(t, odsStyleNS, "condition"), odsAttr(t, odsStyleNS, "apply-style-name")})
+				continue
+			}
+			if t.Name.Space != odsNumberNS {
+				continue
+			}
+			long := odsAttr(t, odsNumberNS, "style") == "long"
+			switch t.Name.Local {
+			case "number", "scientific-number", "fraction":
+				format.WriteString(odsNumberFormat(t))
+			case "year":
+				format.WriteString(odsChoose(long, "yyyy", "yy"))
+			case "month":
+				if odsAttr(t, odsNumberNS, "textual") == "true" {
+					format.WriteString(odsChoose(long, "mmmm", "mmm"))
+				} else {
+					format.WriteString(odsChoose(long, "mm", "m"))
+				}
+			case "day":
+				format.WriteString(odsChoose(long, "dd", "d"))
+			case "day-of-week":
+				format.WriteString(odsChoose(long, "dddd", "ddd"))
+			case "hours":
+				format.WriteString(odsChoose(long, "hh", "h"))
+			case "minutes":
+				format.WriteString(odsChoose(long, "mm", "m"))
+			case "seconds":
+				format.WriteString(odsChoose(long, "ss", "s"))
+				if decimals, _ := strconv.Atoi(odsAttr(t, odsNumberNS, "decimal-places")); decimals > 0 {
+					format.WriteString("." + strings.Repeat("0", decimals))
+				}
+			case "am-pm":
+				format.WriteString("AM/PM")
+			case "text-content":
+				format.WriteString("@")
+			case "text", "currency-symbol":
+				in_text = true
+				text.Reset()
+			}
+		case xml.CharData:
+			if in_text {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name == start.Name {
+				data_style.format = format.String()
+				r.data_styles[odsAttr(start, odsStyleNS, "name")] = data_style
+				return nil
+			}
+			if t.Name.Space == odsNumberNS && (t.Name.Local == "text" || t.Name.Local == "currency-symbol") {
+				in_text = false
+				format.WriteString(odsLiteral(text.String(), start.Name.Local == "percentage-style"))
+			}
+		}
+	}
+}
+
+// Translate number:number, number:scientific-number and number:fraction
+func odsNumberFormat(t xml.StartElement) string {
+	integers := 1
+	if v := odsAttr(t, odsNumberNS, "min-integer-digits"); v != "" {
+		integers, _ = strconv.Atoi(v)
+	}
+	format := strings.Repeat("0", integers)
+	if odsAttr(t, odsNumberNS, "grouping") == "true" {
+		format = strings.Repeat("#", odsMax(4-integers, 0)) + format
+		format = format[:len(format)-3] + "," + format[len(format)-3:]
+	} else if integers == 0 {
+		format = "#"
+	}
+	switch t.Name.Local {
+	case "fraction":
+		numerator, _ := strconv.Atoi(odsAttr(t, odsNumberNS, "min-numerator-digits"))
+		denominator := strings.Repeat("?", odsMax(1, odsAtoi(odsAttr(t, odsNumberNS, "min-denominator-digits"))))
+		if v := odsAttr(t, odsNumberNS, "denominator-value"); v != "" {
+			denominator = v
+		}
+		return format + " " + strings.Repeat("?", odsMax(numerator, 1)) + "/" + denominator
+	}
+	if v := odsAttr(t, odsNumberNS, "decimal-places"); v != "" {
+		decimals := odsAtoi(v)
+		min_decimals := decimals
+		if v := odsAttr(t, odsNumberNS, "min-decimal-places"); v != "" {
+			min_decimals = odsAtoi(v)
+		}
+		if decimals > 0 {
+			format += "." + strings.Repeat("0", min_decimals) + strings.Repeat("#", odsMax(decimals-min_decimals, 0))
+		}
+	}
+	if t.Name.Local == "scientific-number" {
+		format += "E+" + strings.Repeat("0", odsMax(2, odsAtoi(odsAttr(t, odsNumberNS, "min-exponent-digits"))))
+	}
+	return format
+}
+
+// Quote the literal text of a number format. The percent sign of percentage
+// styles scales the value.
+func odsLiteral(text string, percentage bool) string {
+	quote := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "\"" + strings.ReplaceAll(s, "\"", "\"\\\"\"") + "\""
+	}
+	if !percentage {
+		return quote(text)
+	}
+	parts := strings.Split(text, "%")
+	for i := range parts {
+		parts[i] = quote(parts[i])
+	}
+	return strings.Join(parts, "%")
+}
+
+// Add a named range of the spreadsheet as a defined name. Named ranges of a
+// sheet are scoped to the sheet.
+func (r *odsReader) addDefinedName(name string, address string) error {
+	if name == "" || address == "" {
+		return nil
+	}
+	ranges := strings.Fields(address)
+	for i := range ranges {
+		ranges[i] = odsReference(ranges[i])
+	}
+	return r.f.SetDefinedName(&excelize.DefinedName{Name: name, RefersTo: strings.Join(ranges, ","), Scope: r.sheet})
+}
+
+// Read the attributes and the text of a cell. The text of the paragraphs is
+// joined with new lines, annotations are ignored.
+func readODSCell(d *xml.Decoder, start xml.StartElement) (odsCell, error) {
+	cell := odsCell{
+		repeat:     odsRepeat(start, "number-columns-repeated"),
+		col_span:   odsAtoi(odsAttr(start, odsTableNS, "number-columns-spanned")),
+		row_span:   odsAtoi(odsAttr(start, odsTableNS, "number-rows-spanned")),
+		style:      odsAttr(start, odsTableNS, "style-name"),
+		value_type: odsAttr(start, odsOfficeNS, "value-type"),
+		formula:    odsAttr(start, odsTableNS, "formula"),
+	}
+	switch cell.value_type {
+	case "date":
+		cell.value = odsAttr(start, odsOfficeNS, "date-value")
+	case "time":
+		cell.value = odsAttr(start, odsOfficeNS, "time-value")
+	case "boolean":
+		cell.value = odsAttr(start, odsOfficeNS, "boolean-value")
+	case "string":
+		cell.value = odsAttr(start, odsOfficeNS, "string-value")
+	default:
+		cell.value = odsAttr(start, odsOfficeNS, "value")
+	}
+
+	var text strings.Builder
+	paragraphs := 0
+	depth := 0
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return cell, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space == odsOfficeNS && t.Name.Local == "annotation" {
+				if err := d.Skip(); err != nil {
+					return cell, err
+				}
+				continue
+			}
+			if t.Name.Space != odsTextNS {
+				continue
+			}
+			switch t.Name.Local {
+			case "p", "h":
+				if paragraphs > 0 {
+					text.WriteString("\n")
+				}
+				paragraphs++
+				depth++
+			case "s":
+				text.WriteString(strings.Repeat(" ", odsMax(odsAtoi(odsAttr(t, odsTextNS, "c")), 1)))
+			case "tab":
+				text.WriteString("\t")
+			case "line-break":
+				text.WriteString("\n")
+			}
+		case xml.CharData:
+			if depth > 0 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name == start.Name {
+				cell.text = text.String()
+				if cell.value_type == "string" && cell.text == "" {
+					cell.text = cell.value
+				}
+				return cell, nil
+			}
+			if t.Name.Space == odsTextNS && (t.Name.Local == "p" || t.Name.Local == "h") {
+				depth--
+			}
+		}
+	}
+}
+
+var odsDurationPattern = regexp.MustCompile(`^(-)?P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// Convert an ISO 8601 duration (ex. PT10H30M00S) to a fraction of days
+func parseODSDuration(s string) (float64, bool) {
+	m := odsDurationPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, false
+	}
+	var days float64
+	for i, unit := range []float64{1, 24, 24 * 60, 24 * 60 * 60} {
+		if m[i+2] != "" {
+			n, _ := strconv.ParseFloat(m[i+2], 64)
+			days += n / unit
+		}
+	}
+	if m[1] != "" {
+		days = -days
+	}
+	return days, true
+}
+
+// Convert an OpenFormula expression to an excel formula. The references like
+// [.A1:.B2] or [$Sheet2.A1] are converted to A1:B2 and Sheet2!A1 and the
+// function arguments are separated with commas.
+func odsFormula(formula string) string {
+	if i := strings.Index(formula, "="); i >= 0 && !strings.ContainsAny(formula[:i], "\"([") {
+		formula = formula[i+1:]
+	}
+	var sb strings.Builder
+	quoted := false
+	for i := 0; i < len(formula); i++ {
+		c := formula[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+			sb.WriteByte(c)
+		case quoted:
+			sb.WriteByte(c)
+		case c == '[':
+			end := strings.IndexByte(formula[i:], ']')
+			if end < 0 {
+				sb.WriteString(formula[i:])
+				return sb.String()
+			}
+			sb.WriteString(odsReference(formula[i+1 : i+end]))
+			i += end
+		case c == ';':
+			sb.WriteByte(',')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// Convert a cell reference or range like $Sheet1.$A$1:.$D$10 to an excel
+// reference like Sheet1!$A$1:$D$10
+func odsReference(ref string) string {
+	var sheets, cells []string
+	for _, part := range strings.Split(ref, ":") {
+		sheet, cell := "", strings.TrimPrefix(part, "$")
+		if strings.HasPrefix(cell, "'") {
+			end := 1
+			for end < len(cell) {
+				if cell[end] == '\'' {
+					if end+1 < len(cell) && cell[end+1] == '\'' {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end < len(cell) {
+				sheet, cell = cell[:end+1], cell[end+1:]
+			}
+		} else if i := strings.LastIndex(cell, "."); i >= 0 {
+			sheet, cell = cell[:i], cell[i:]
+		}
+		sheets = append(sheets, sheet)
+		cells = append(cells, strings.TrimPrefix(cell, "."))
+	}
+	prefix := ""
+	if sheets[0] != "" {
+		prefix = sheets[0]
+		if last := sheets[len(sheets)-1]; last != "" && last != sheets[0] {
+			prefix += ":" + last
+		}
+		prefix += "!"
+	}
+	return prefix + strings.Join(cells, ":")
+}
+
+func odsAttr(element xml.StartElement, space string, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Space == space && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Get the repeat count of a row or column, 1 if not repeated
+func odsRepeat(element xml.StartElement, name string) int {
+	return odsMax(odsAtoi(odsAttr(element, odsTableNS, name)), 1)
+}
+
+func odsAtoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func odsMax(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func odsChoose(long bool, long_format string, short_format string) string {
+	if long {
+		return long_format
+	}
+	return short_format
+}
@@ -0,0 +1,397 @@
+package config
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const odsNamespaces = `xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" ` +
+	`xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" ` +
+	`xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" ` +
+	`xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" ` +
+	`xmlns:number="urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0" ` +
+	`xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"`
+
+const odsTestStyles = `<office:document-styles ` + odsNamespaces + `><office:styles>
+<style:style style:name="Default" style:family="table-cell"/>
+<style:style style:name="Struck" style:family="table-cell"><style:text-properties style:text-line-through-style="solid" fo:color="#ff0000"/></style:style>
+</office:styles></office:document-styles>`
+
+const odsTestContent = `<office:document-content ` + odsNamespaces + `>
+<office:automatic-styles>
+<number:number-style style:name="N2"><number:number number:decimal-places="2" number:min-integer-digits="1" number:grouping="true"/></number:number-style>
+<number:percentage-style style:name="P0"><number:number number:decimal-places="0" number:min-integer-digits="1"/><number:text>%</number:text></number:percentage-style>
+<number:currency-style style:name="C0P0"><number:number number:decimal-places="2" number:min-integer-digits="1"/><number:text> </number:text><number:currency-symbol>€</number:currency-symbol></number:currency-style>
+<number:currency-style style:name="C0"><number:text>-</number:text><number:number number:decimal-places="2" number:min-integer-digits="1"/><number:text> </number:text><number:currency-symbol>€</number:currency-symbol><style:map style:condition="value()&gt;=0" style:apply-style-name="C0P0"/></number:currency-style>
+<number:date-style style:name="D1"><number:day number:style="long"/><number:text>/</number:text><number:month number:style="long"/><number:text>/</number:text><number:year number:style="long"/></number:date-style>
+<style:style style:name="ce1" style:family="table-cell" style:data-style-name="N2"/>
+<style:style style:name="ce2" style:family="table-cell" style:data-style-name="P0"/>
+<style:style style:name="ce3" style:family="table-cell" style:data-style-name="C0"/>
+<style:style style:name="ce4" style:family="table-cell" style:data-style-name="D1"/>
+<style:style style:name="ce5" style:family="table-cell" style:parent-style-name="Struck"><style:table-cell-properties fo:background-color="#ffff00"/></style:style>
+<style:style style:name="ta1" style:family="table"><style:table-properties table:display="true"/></style:style>
+<style:style style:name="ta2" style:family="table"><style:table-properties table:display="false"/></style:style>
+</office:automatic-styles>
+<office:body><office:spreadsheet>
+<table:table table:name="servers" table:style-name="ta1">
+<table:table-column table:number-columns-repeated="2"/><table:table-column table:visibility="collapse"/><table:table-column table:number-columns-repeated="1021"/>
+<table:table-row>
+<table:table-cell office:value-type="string"><text:p>name</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>cpu</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>secret</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>usage</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>cost</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>since</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>checked</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>uptime</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>active</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>total</text:p></table:table-cell>
+<table:table-cell table:number-columns-repeated="1014"/>
+</table:table-row>
+<table:table-row>
+<table:table-cell office:value-type="string"><text:p>web<text:s text:c="2"/>1</text:p><text:p>line 2</text:p><office:annotation><text:p>note</text:p></office:annotation></table:table-cell>
+<table:table-cell table:style-name="ce1" office:value-type="float" office:value="1234.5"><text:p>1,234.50</text:p></table:table-cell>
+<table:table-cell office:value-type="string"><text:p>s</text:p></table:table-cell>
+<table:table-cell table:style-name="ce2" office:value-type="percentage" office:value="0.25"><text:p>25%</text:p></table:table-cell>
+<table:table-cell table:style-name="ce3" office:value-type="currency" office:currency="EUR" office:value="-3.5"><text:p>-3.50 €</text:p></table:table-cell>
+<table:table-cell table:style-name="ce4" office:value-type="date" office:date-value="2024-01-31"><text:p>31/01/2024</text:p></table:table-cell>
+<table:table-cell office:value-type="date" office:date-value="2024-01-31T10:30:00"><text:p>2024-01-31 10:30</text:p></table:table-cell>
+<table:table-cell office:value-type="time" office:time-value="PT12H00M00S"><text:p>12:00:00</text:p></table:table-cell>
+<table:table-cell office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell>
+<table:table-cell table:formula="of:=SUM([.B2];[.D2])" office:value-type="float" office:value="1234.75"><text:p>1234.75</text:p></table:table-cell>
+</table:table-row>
+<table:table-row table:number-rows-repeated="2">
+<table:table-cell table:style-name="ce5" office:value-type="string"><text:p>db</text:p></table:table-cell>
+<table:table-cell office:value-type="float" office:value="4" table:number-columns-repeated="2"><text:p>4</text:p></table:table-cell>
+</table:table-row>
+<table:table-row table:visibility="collapse">
+<table:table-cell table:number-columns-spanned="2" table:number-rows-spanned="2" office:value-type="string"><text:p>merged</text:p></table:table-cell>
+<table:table-cell table:number-columns-repeated="1022"/>
+</table:table-row>
+<table:table-row table:number-rows-repeated="1048570"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+</table:table>
+<table:table table:name="hidden" table:style-name="ta2">
+<table:table-row><table:table-cell office:value-type="string"><text:p>x</text:p></table:table-cell></table:table-row>
+</table:table>
+<table:named-expressions><table:named-range table:name="data" table:base-cell-address="$servers.$A$1" table:cell-range-address="$servers.$A$1:.$B$4"/></table:named-expressions>
+</office:spreadsheet></office:body></office:document-content>`
+
+// Create an ods package with the styles and content xml
+func newODS(t *testing.T, styles string, content string) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for _, file := range []struct{ name, data string }{
+		{"mimetype", odsMimeType},
+		{"META-INF/manifest.xml", `<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0"><manifest:file-entry manifest:full-path="/" manifest:media-type="` + odsMimeType + `"/></manifest:manifest>`},
+		{"styles.xml", styles},
+		{"content.xml", content},
+	} {
+		w, err := z.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(file.data))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestConvertODS(t *testing.T) {
+	data, err := convertODS("", newODS(t, odsTestStyles, odsTestContent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tests := []struct {
+		cell    string
+		value   string
+		format  string
+		formula string
+	}{
+		{cell: "A1", value: "name"},
+		{cell: "A2", value: "web  1\nline 2"},
+		{cell: "B2", value: "1234.5", format: "#,##0.00"},
+		{cell: "D2", value: "0.25", format: "0%"},
+		{cell: "E2", value: "-3.5", format: `0.00" ""€";"-"0.00" ""€"`},
+		{cell: "F2", value: "45322", format: `dd"/"mm"/"yyyy`},
+		{cell: "G2", value: "45322.4375", format: "yyyy-mm-dd hh:mm:ss"},
+		{cell: "H2", value: "0.5", format: "hh:mm:ss"},
+		{cell: "I2", value: "1"},
+		{cell: "J2", value: "1234.75", formula: "SUM(B2,D2)"},
+		{cell: "A3", value: "db"},
+		{cell: "B3", value: "4"},
+		{cell: "C3", value: "4"},
+		{cell: "A4", value: "db"},
+		{cell: "C4", value: "4"},
+		{cell: "A5", value: "merged"},
+		{cell: "D3", value: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cell, func(t *testing.T) {
+			value, err := f.GetCellValue("servers", tt.cell, excelize.Options{RawCellValue: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != tt.value {
+				t.Fatalf("expected value %q, got %q", tt.value, value)
+			}
+			id, err := f.GetCellStyle("servers", tt.cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			style, err := getCellStyle(f, id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			format := ""
+			if style.CustomNumFmt != nil {
+				format = *style.CustomNumFmt
+			}
+			if format != tt.format {
+				t.Fatalf("expected format %q, got %q", tt.format, format)
+			}
+			formula, _ := f.GetCellFormula("servers", tt.cell)
+			if formula != tt.formula {
+				t.Fatalf("expected formula %q, got %q", tt.formula, formula)
+			}
+		})
+	}
+
+	t.Run("style", func(t *testing.T) {
+		id, _ := f.GetCellStyle("servers", "A3")
+		style, err := f.GetStyle(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if style.Font == nil || !style.Font.Strike || normalizeColor(style.Font.Color) != "FF0000" {
+			t.Fatalf("expected a red strikethrough font, got %+v", style.Font)
+		}
+		if len(style.Fill.Color) == 0 || normalizeColor(style.Fill.Color[0]) != "FFFF00" {
+			t.Fatalf("expected a yellow fill, got %+v", style.Fill)
+		}
+	})
+	t.Run("merged cells", func(t *testing.T) {
+		merges, err := f.GetMergeCells("servers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(merges) != 1 || merges[0].GetStartAxis() != "A5" || merges[0].GetEndAxis() != "B6" {
+			t.Fatalf("expected merged cells A5:B6, got %v", merges)
+		}
+	})
+	t.Run("hidden", func(t *testing.T) {
+		if visible, _ := f.GetRowVisible("servers", 5); visible {
+			t.Fatal("expected row 5 to be hidden")
+		}
+		if visible, _ := f.GetRowVisible("servers", 4); !visible {
+			t.Fatal("expected row 4 to be visible")
+		}
+		if visible, _ := f.GetColVisible("servers", "C"); visible {
+			t.Fatal("expected column C to be hidden")
+		}
+		if visible, _ := f.GetColVisible("servers", "D"); !visible {
+			t.Fatal("expected column D to be visible")
+		}
+		if visible, _ := f.GetSheetVisible("hidden"); visible {
+			t.Fatal("expected sheet hidden to be hidden")
+		}
+		if f.GetSheetName(f.GetActiveSheetIndex()) != "servers" {
+			t.Fatal("expected sheet servers to be active")
+		}
+	})
+	t.Run("repeated empty rows", func(t *testing.T) {
+		rows, err := f.GetRows("servers")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 5 || len(rows[0]) != 10 || len(rows[2]) != 3 {
+			t.Fatalf("expected 5 rows and 10 columns, got %d rows", len(rows))
+		}
+	})
+	t.Run("named range", func(t *testing.T) {
+		for _, dn := range f.GetDefinedName() {
+			if dn.Name == "data" {
+				if dn.RefersTo != "servers!$A$1:$B$4" {
+					t.Fatalf("expected servers!$A$1:$B$4, got %s", dn.RefersTo)
+				}
+				return
+			}
+		}
+		t.Fatal("defined name data not found")
+	})
+}
+
+func TestConvertODSNotODS(t *testing.T) {
+	f := excelize.NewFile()
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{buf.Bytes(), []byte("name,cpu\nweb,2\n")} {
+		converted, err := convertODS("", data)
+		if err != nil || converted != nil {
+			t.Fatalf("expected no conversion, got %d bytes and %v", len(converted), err)
+		}
+	}
+}
+
+func TestConvertODSErrors(t *testing.T) {
+	// the manifest of an encrypted package has the encryption data
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for _, file := range []struct{ name, data string }{
+		{"mimetype", odsMimeType},
+		{"META-INF/manifest.xml", `<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0"><manifest:file-entry manifest:full-path="content.xml"><manifest:encryption-data/></manifest:file-entry></manifest:manifest>`},
+	} {
+		w, err := z.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(file.data))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := buf.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{name: "encrypted", data: encrypted, err: "password protected ods workbooks are not supported"},
+		{name: "no sheets", data: newODS(t, "", `<office:document-content `+odsNamespaces+`><office:body><office:spreadsheet/></office:body></office:document-content>`), err: "ods workbook has no sheets"},
+		{name: "invalid xml", data: newODS(t, "", `<office:document-content `+odsNamespaces+`><table:table table:name="a">`), err: "unable to read content.xml of the ods workbook"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := convertODS("", tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// Get the first element of the xml
+func odsTestElement(t *testing.T, s string) xml.StartElement {
+	d := xml.NewDecoder(strings.NewReader(`<root xmlns:number="` + odsNumberNS + `">` + s + `</root>`))
+	for {
+		token, err := d.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local != "root" {
+			return start
+		}
+	}
+}
+
+func TestODSNumberFormat(t *testing.T) {
+	tests := []struct {
+		element string
+		format  string
+	}{
+		{element: `<number:number number:decimal-places="2" number:min-integer-digits="1"/>`, format: "0.00"},
+		{element: `<number:number number:decimal-places="0" number:min-integer-digits="1" number:grouping="true"/>`, format: "#,##0"},
+		{element: `<number:number number:decimal-places="3" number:min-decimal-places="1" number:min-integer-digits="1"/>`, format: "0.0##"},
+		{element: `<number:number number:min-integer-digits="0"/>`, format: "#"},
+		{element: `<number:scientific-number number:decimal-places="2" number:min-integer-digits="1" number:min-exponent-digits="3"/>`, format: "0.00E+000"},
+		{element: `<number:fraction number:min-integer-digits="0" number:min-numerator-digits="1" number:min-denominator-digits="2"/>`, format: "# ?/??"},
+		{element: `<number:fraction number:min-integer-digits="1" number:denominator-value="8"/>`, format: "0 ?/8"},
+	}
+	for _, tt := range tests {
+		if format := odsNumberFormat(odsTestElement(t, tt.element)); format != tt.format {
+			t.Fatalf("%s: expected %q, got %q", tt.element, tt.format, format)
+		}
+	}
+}
+
+func TestODSLiteral(t *testing.T) {
+	tests := []struct {
+		text       string
+		percentage bool
+		literal    string
+	}{
+		{text: "", literal: ""},
+		{text: " €", literal: `" €"`},
+		{text: `say "hi"`, literal: `"say "\""hi"\"""`},
+		{text: "%", literal: `"%"`},
+		{text: "%", percentage: true, literal: "%"},
+		{text: " % done", percentage: true, literal: `" "%" done"`},
+	}
+	for _, tt := range tests {
+		if literal := odsLiteral(tt.text, tt.percentage); literal != tt.literal {
+			t.Fatalf("odsLiteral(%q, %v): expected %s, got %s", tt.text, tt.percentage, tt.literal, literal)
+		}
+	}
+}
+
+func TestODSFormula(t *testing.T) {
+	tests := []struct {
+		formula string
+		excel   string
+	}{
+		{formula: "of:=SUM([.A1:.B2])", excel: "SUM(A1:B2)"},
+		{formula: "of:=IF([.A1]>0;\"a;[b]\";[$Sheet2.B1])", excel: "IF(A1>0,\"a;[b]\",Sheet2!B1)"},
+		{formula: "=[$'My Sheet'.$A$1]*2", excel: "'My Sheet'!$A$1*2"},
+		{formula: "of:=SUM([$S1.A1:$S3.B2])", excel: "SUM(S1:S3!A1:B2)"},
+		{formula: "of:=[.A1", excel: "[.A1"},
+	}
+	for _, tt := range tests {
+		if excel := odsFormula(tt.formula); excel != tt.excel {
+			t.Fatalf("odsFormula(%q): expected %q, got %q", tt.formula, tt.excel, excel)
+		}
+	}
+}
+
+func TestODSReference(t *testing.T) {
+	tests := []struct {
+		ref   string
+		excel string
+	}{
+		{ref: ".A1", excel: "A1"},
+		{ref: "$Sheet1.$A$1:.$D$10", excel: "Sheet1!$A$1:$D$10"},
+		{ref: "$'It''s.here'.A1", excel: "'It''s.here'!A1"},
+		{ref: "Sheet1.A1:Sheet1.B2", excel: "Sheet1!A1:B2"},
+		{ref: "$S1.A1:$S3.B2", excel: "S1:S3!A1:B2"},
+	}
+	for _, tt := range tests {
+		if excel := odsReference(tt.ref); excel != tt.excel {
+			t.Fatalf("odsReference(%q): expected %q, got %q", tt.ref, tt.excel, excel)
+		}
+	}
+}
+
+func TestParseODSDuration(t *testing.T) {
+	tests := []struct {
+		duration string
+		days     float64
+		ok       bool
+	}{
+		{duration: "PT12H00M00S", days: 0.5, ok: true},
+		{duration: "PT06H30M", days: 6.5 / 24, ok: true},
+		{duration: "P1DT6H", days: 1.25, ok: true},
+		{duration: "-PT12H", days: -0.5, ok: true},
+		{duration: "PT0.5S", days: 0.5 / 86400, ok: true},
+		{duration: "12:00", ok: false},
+	}
+	for _, tt := range tests {
+		days, ok := parseODSDuration(tt.duration)
+		if ok != tt.ok || days != tt.days {
+			t.Fatalf("parseODSDuration(%q): expected %v %v, got %v %v", tt.duration, tt.days, tt.ok, days, ok)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -25,6 +26,8 @@ type RequestParameters struct {
 	authorization string
 	method        string
 	payload       string
+	timeout       time.Duration
+	max_size      int64
 }
 
 type TokenRequestParameters struct {
@@ -153,8 +156,7 @@ func dataSourceRestApiRead(ctx context.Context, d *schema.ResourceData, m interf
 
 	}
 
-	data, err := createR
-	equest(reqparm)
+	data, err := createRequest(reqparm)
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -168,6 +170,15 @@ func dataSourceRestApiRead(ctx context.Context, d *schema.ResourceData, m interf
 }
 
 func createRequest(args *RequestParameters) (string, error) {
+	responseData, _, err := sendRequest(args)
+	if err != nil {
+		return "", err
+	}
+	return string(responseData), nil
+}
+
+// Send the http request and get the body and status code of the response
+func sendRequest(args *RequestParameters) ([]byte, int, error) {
 	param := url.Values{}
 	for _, p := range args.params {
 		param.Add(p["Key"].(string), p["Value"].(string))
@@ -183,10 +194,10 @@ func createRequest(args *RequestParameters) (string, error) {
 	if args.payload != "" {
 		payload = strings.NewReader(args.payload)
 	}
-	client := &http.Client{}
+	client := &http.Client{Timeout: args.timeout}
 	req, err := http.NewRequest(method, url, payload)
 	if err != nil {
-		return "", err
+		return nil, 0, err
 	}
 
 	// add basic authentication
@@ -204,14 +215,21 @@ func createRequest(args *RequestParameters) (string, error) {
 	// Send http request
 	response, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, 0, err
 	}
 
 	defer response.Body.Close()
-	responseData, err := ioutil.ReadAll(response.Body)
+	var body io.Reader = response.Body
+	if args.max_size > 0 {
+		body = io.LimitReader(response.Body, args.max_size+1)
+	}
+	responseData, err := ioutil.ReadAll(body)
 	if err != nil {
-		return "", err
+		return nil, 0, err
+	}
+	if args.max_size > 0 && int64(len(responseData)) > args.max_size {
+		return nil, 0, fmt.Errorf("response of %s is larger than %d bytes", args.uri, args.max_size)
 	}
 
-	return string(responseData), nil
+	return responseData, response.StatusCode, nil
 }